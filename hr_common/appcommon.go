@@ -27,6 +27,14 @@ const (
 	DbHrClients       = DbPrefix + "hr_clients"
 	DbHrProjects      = DbPrefix + "hr_projects"
 	DbHrOvertimes     = DbPrefix + "hr_overtimes"
+
+	DbHrAttendanceRegularizations = DbPrefix + "hr_attendance_regularizations"
+	DbHrAttendanceAnomalies       = DbPrefix + "hr_attendance_anomalies"
+	DbHrLeaveBalances             = DbPrefix + "hr_leave_balances"
+	DbHrLeaveEncashments          = DbPrefix + "hr_leave_encashments"
+	DbHrLeavePolicies             = DbPrefix + "hr_leave_policies"
+	DbHrRosters                   = DbPrefix + "hr_rosters"
+	DbHrShiftSwaps                = DbPrefix + "hr_shift_swaps"
 )
 
 // Dynamic Fields
@@ -44,17 +52,48 @@ const (
 
 	FLD_FILTERED_COUNT = "filtered_count"
 	FLD_GROUP_DOCS     = "docs"
+
+	FLD_TOTAL_WORKED_MINUTES = "total_worked_minutes"
+	FLD_TOTAL_BREAK_MINUTES  = "total_break_minutes"
+
+	FLD_DAILY_STATUS   = "daily_status"
+	FLD_STATUS_SUMMARY = "status_summary"
+
+	// Date-Time rendered in the stored timezone
+	FLD_LOCAL_DATETIME     = "local_date_time"
+	FLD_LEAVE_FROM_LOCAL   = "leave_from_local"
+	FLD_LEAVE_TO_LOCAL     = "leave_to_local"
+	FLD_HOLIDAY_DATE_LOCAL = "holiday_date_local"
+
+	// Date-Time migration result fields
+	FLD_MIGRATED_COUNT = "migrated_count"
+	FLD_FAILED_RECORDS = "failed_records"
 )
 
 // HR Module table fields
 const (
 	// Common fields for all tables
 	FLD_BUSINESS_ID = platform_common.FLD_BUSINESS_ID
+	FLD_TIMEZONE    = "timezone" // IANA zone of the date-time values stored in UTC
+
+	// Blob Store props
+	FLD_BLOB_STORE_TYPE = "blob_store_type" // BLOB_STORE_TYPE_*
+	FLD_BLOB_STORE_PATH = "blob_store_path" // Base directory of the local filesystem store
 
 	// Staff table fields
 	FLD_STAFF_ID            = "staff_id"
 	FLD_STAFF_DATA          = "staff_data"
 	FLD_STAFF_LAST_CLOCK_IN = "last_clock_in_attendance_id"
+	FLD_DEVICE_USER_ID      = "device_user_id"     // User id of the staff in the biometric device
+	FLD_REPORTING_TO        = "reporting_to"       // Staff id of the reporting manager
+	FLD_REGISTERED_DEVICES  = "registered_devices" // Devices allowed for the staff to Clock-In/Clock-Out
+	FLD_DEVICE_FINGERPRINT  = "device_fingerprint"
+	FLD_DEVICE_NAME         = "device_name"
+	FLD_REGISTERED_BY       = "registered_by"
+	FLD_REGISTERED_AT       = "registered_at"
+
+	// Attendance service props
+	FLD_REQUIRE_DEVICE_BINDING = "require_device_binding" // Reject the punches of the staff having no registered devices
 
 	// StaffType table fields
 	FLD_STAFFTYPE_ID          = "staff_type_id"
@@ -66,10 +105,45 @@ const (
 	FLD_LEAVETYPE_NAME = "leave_type_name"
 	FLD_LEAVETYPE_DESC = "leave_type_desc"
 
+	// Leave Type entitlement fields
+	FLD_ANNUAL_QUOTA      = "annual_quota"      // Days credited at the start of the year
+	FLD_MONTHLY_ACCRUAL   = "monthly_accrual"   // Days credited every month
+	FLD_MAX_CARRY_FORWARD = "max_carry_forward" // Days allowed to carry forward to the next year
+	FLD_APPROVAL_CHAIN    = "approval_chain"    // Array of APPROVER_* to approve the leave in order
+	FLD_MAX_ENCASHMENT    = "max_encashment"    // Days allowed to encash at the year end, rest of the balance lapses
+
+	FLD_COUNT_SANDWICH_HOLIDAYS = "count_sandwich_holidays" // Charge the holidays & weekly offs within the leave
+
+	// Leave Type comp-off fields
+	FLD_IS_COMP_OFF            = "is_comp_off"            // Leave type availed against the comp-off credits
+	FLD_COMP_OFF_VALIDITY_DAYS = "comp_off_validity_days" // Days the comp-off credit can be availed after the work date
+
+	// Leave Type permission caps, no limit when zero
+	FLD_MAX_PERMISSIONS_PER_MONTH      = "max_permissions_per_month"
+	FLD_MAX_PERMISSION_HOURS_PER_MONTH = "max_permission_hours_per_month"
+
+	// Leave Type document rules
+	FLD_DOCUMENT_RULES      = "document_rules"      // Array of document_type with required_above_days
+	FLD_DOCUMENT_TYPE       = "document_type"       // Like medical_certificate
+	FLD_REQUIRED_ABOVE_DAYS = "required_above_days" // Document is required when the leave is longer than the days
+
+	// Leave Policy table fields
+	FLD_LEAVE_POLICY_ID   = "leave_policy_id"
+	FLD_LEAVE_POLICY_NAME = "leave_policy_name"
+	FLD_LEAVE_POLICY_DESC = "leave_policy_desc"
+	FLD_EFFECTIVE_FROM    = "effective_from"   // Date (YYYY-MM-DD) the policy applies from
+	FLD_EFFECTIVE_TO      = "effective_to"     // Last date (YYYY-MM-DD) the policy applies, open ended when not set
+	FLD_LEAVE_TYPE_RULES  = "leave_type_rules" // Array of leave_type_id with the leave type fields to override
+
 	// Department table fields
 	FLD_DEPARTMENT_ID   = "department_id"
 	FLD_DEPARTMENT_NAME = "department_name"
 	FLD_DEPARTMENT_DESC = "department_desc"
+	FLD_DEPARTMENT_HEAD = "department_head_id" // Staff id of the head of the department
+
+	// Coverage rule of the department & project
+	FLD_MIN_COVERAGE    = "min_coverage"    // Minimum staff to be available on a day
+	FLD_COVERAGE_ACTION = "coverage_action" // COVERAGE_ACTION_WARN or COVERAGE_ACTION_BLOCK
 
 	// Holiday table fileds
 	FLD_HOLIDAY_ID          = "holiday_id"
@@ -84,6 +158,7 @@ const (
 
 	FLD_POSITION_ID   = "position_id"
 	FLD_POSITION_NAME = "position_name"
+	FLD_REPORTS_TO    = "reports_to_position_id" // Parent position in the hierarchy
 
 	FLD_POSITION_TYPE_ID   = "position_type_id"
 	FLD_POSITION_TYPE_NAME = "position_type_name"
@@ -95,6 +170,86 @@ const (
 	FLD_LONGITUDE     = "longitude"
 	FLD_CLOCK_IN      = "clock_in"
 	FLD_CLOCK_OUT     = "clock_out"
+	FLD_IS_OFF_SITE   = "is_off_site"
+	FLD_OFF_SITE_DIST = "off_site_distance" // Distance in meters from the work location
+	FLD_AUTO_CLOSED   = "auto_closed"       // Clock-Out generated by the system
+
+	// Attendance computed fields
+	FLD_WORKED_MINUTES     = "worked_minutes"
+	FLD_BREAK_MINUTES      = "break_minutes"
+	FLD_LATE_BY_MINUTES    = "late_by_minutes"
+	FLD_EARLY_EXIT_MINUTES = "early_exit_minutes"
+	FLD_PAID_BREAK_MINUTES = "paid_break_minutes"
+
+	// Attendance Breaks
+	FLD_BREAKS        = "breaks"
+	FLD_BREAK_ID      = "break_id"
+	FLD_BREAK_START   = "break_start"
+	FLD_BREAK_END     = "break_end"
+	FLD_IS_PAID_BREAK = "is_paid"
+
+	// Attendance Service props
+	FLD_OPEN_SESSION_POLICY      = "open_session_policy"      // OPEN_SESSION_POLICY_REJECT or OPEN_SESSION_POLICY_AUTO_CLOSE
+	FLD_AUTO_CLOSE_GRACE_MINUTES = "auto_close_grace_minutes" // Minutes after shift end to auto clock-out
+
+	// Auto-Close result fields
+	FLD_CLOSED_SESSIONS  = "closed_sessions"
+	FLD_SKIPPED_SESSIONS = "skipped_sessions"
+	FLD_REASON           = "reason"
+
+	// Attendance Regularization Table
+	FLD_REGULARIZATION_ID     = "regularization_id"
+	FLD_REGULARIZATION_STATUS = "regularization_status" // REGULARIZATION_STATUS_*
+	FLD_REQUESTED_CLOCK_IN    = "requested_clock_in"
+	FLD_REQUESTED_CLOCK_OUT   = "requested_clock_out"
+	FLD_ORIGINAL_CLOCK_IN     = "original_clock_in"  // Clock-In before the approval, kept for audit
+	FLD_ORIGINAL_CLOCK_OUT    = "original_clock_out" // Clock-Out before the approval, kept for audit
+	FLD_ACTED_BY              = "acted_by"
+	FLD_ACTED_AT              = "acted_at"
+	FLD_REMARKS               = "remarks"
+	FLD_IS_REGULARIZED        = "is_regularized" // Attendance corrected by an approved regularization
+
+	// Daily attendance status fields
+	FLD_DATE              = "date"
+	FLD_ATTENDANCE_STATUS = "attendance_status" // ATTENDANCE_STATUS_*
+	FLD_ATTENDANCE_IDS    = "attendance_ids"
+	FLD_HALF_DAY_MINUTES  = "half_day_minutes" // Attendance Service prop, minimum worked minutes for half-day
+	FLD_FULL_DAY_MINUTES  = "full_day_minutes" // Attendance Service prop, minimum worked minutes for present
+
+	// Punch log import fields
+	FLD_PUNCH_LOG        = "punch_log"        // Content of the device export
+	FLD_PUNCH_LOG_FORMAT = "punch_log_format" // PUNCH_LOG_FORMAT_CSV or PUNCH_LOG_FORMAT_DAT
+	FLD_DEDUPE_MINUTES   = "dedupe_minutes"   // Punches of the staff within these minutes are duplicates
+	FLD_DEVICE_ID        = "device_id"
+	FLD_PUNCH_SOURCE     = "punch_source"
+	FLD_ROW              = "row"
+	FLD_IMPORT_STATUS    = "import_status" // IMPORT_STATUS_*
+	FLD_IMPORT_RESULTS   = "import_results"
+
+	// Punch photo fields
+	FLD_PHOTO              = "photo"     // Base64 image data, data URL prefix is optional
+	FLD_PHOTO_REF          = "photo_ref" // Reference of the photo in the blob store
+	FLD_PHOTO_CONTENT_TYPE = "photo_content_type"
+	FLD_MAX_PHOTO_SIZE_KB  = "max_photo_size_kb" // Attendance Service prop
+
+	// Attendance Anomaly Table
+	FLD_ANOMALY_ID               = "anomaly_id"
+	FLD_ANOMALY_TYPE             = "anomaly_type"   // ANOMALY_TYPE_*
+	FLD_ANOMALY_STATUS           = "anomaly_status" // ANOMALY_STATUS_*
+	FLD_ANOMALY_KEY              = "anomaly_key"    // Identifies the same finding across the detection runs
+	FLD_ANOMALY_DETAILS          = "anomaly_details"
+	FLD_DETECTED_AT              = "detected_at"
+	FLD_DISTANCE                 = "distance" // Distance in meters
+	FLD_SPEED_KMPH               = "speed_kmph"
+	FLD_FROM_PUNCH               = "from" // Earlier punch of the travel
+	FLD_TO_PUNCH                 = "to"   // Later punch of the travel
+	FLD_PUNCH_TYPE               = "punch_type"
+	FLD_SESSION_MINUTES          = "session_minutes"
+	FLD_OTHER_STAFF_IDS          = "other_staff_ids"
+	FLD_ANOMALIES                = "anomalies"
+	FLD_MAX_TRAVEL_SPEED_KMPH    = "max_travel_speed_kmph"    // Attendance Service prop
+	FLD_ODD_HOURS_MARGIN_MINUTES = "odd_hours_margin_minutes" // Attendance Service prop
+	FLD_LONG_SESSION_HOURS       = "long_session_hours"       // Attendance Service prop
 
 	// Leave Table
 	FLD_LEAVE_ID          = "leave_id"
@@ -103,6 +258,75 @@ const (
 	FLD_LEAVE_DESCRIPTION = "leave_description"
 	FLD_LEAVE_APPROVED    = "leave_approved"
 	FLD_LEAVE_TYPE        = "leave_type"
+	FLD_LEAVE_DAYS        = "leave_days"  // Days charged for the leave
+	FLD_LEAVE_UNIT        = "leave_unit"  // LEAVE_UNIT_*
+	FLD_LEAVE_HOURS       = "leave_hours" // Hours of the hourly leave
+
+	FLD_COVERAGE_WARNINGS = "coverage_warnings" // Days the leave drops the team below the minimum coverage
+
+	// Leave attachments
+	FLD_ATTACHMENTS            = "attachments"
+	FLD_ATTACHMENT_ID          = "attachment_id"
+	FLD_ATTACHMENT_REF         = "attachment_ref" // Reference of the attachment in the blob store
+	FLD_FILE_NAME              = "file_name"
+	FLD_CONTENT_TYPE           = "content_type"
+	FLD_CONTENT                = "content" // Base64 file data, data URL prefix is optional
+	FLD_UPLOADED_AT            = "uploaded_at"
+	FLD_MAX_ATTACHMENT_SIZE_KB = "max_attachment_size_kb" // Leave Service prop
+
+	// Permission usage fields
+	FLD_PERMISSION_MONTH      = "permission_month"
+	FLD_PERMISSION_COUNT      = "permission_count"
+	FLD_PERMISSION_HOURS      = "permission_hours"
+	FLD_REMAINING_PERMISSIONS = "remaining_permissions"
+	FLD_REMAINING_HOURS       = "remaining_hours"
+	FLD_PERMISSIONS           = "permissions"
+
+	// Team Calendar fields
+	FLD_TEAM_CALENDAR     = "team_calendar"
+	FLD_TEAM_SIZE         = "team_size"
+	FLD_STAFFS_ON_LEAVE   = "staffs_on_leave"
+	FLD_ON_LEAVE_COUNT    = "on_leave_count"
+	FLD_AVAILABLE_COUNT   = "available_count"
+	FLD_IS_BELOW_COVERAGE = "is_below_coverage"
+
+	// Leave Service props
+	FLD_CHECK_ATTENDANCE_OVERLAP = "check_attendance_overlap" // Reject the leave on the days having attendance
+	FLD_LEAVE_STATUS             = "leave_status"             // LEAVE_STATUS_*
+	FLD_LEAVE_APPROVERS          = "leave_approvers"          // Approvers resolved from the approval chain
+	FLD_APPROVAL_LEVEL           = "approval_level"           // Index of the approver to act next
+	FLD_APPROVAL_HISTORY         = "approval_history"         // Actions taken on the leave
+	FLD_APPROVER_ROLE            = "approver_role"
+	FLD_APPROVER_ID              = "approver_id"
+	FLD_ACTION                   = "action"
+
+	// Leave Balance Table
+	FLD_BALANCE_ENTRY_ID   = "balance_entry_id"
+	FLD_BALANCE_ENTRY_TYPE = "balance_entry_type" // BALANCE_ENTRY_*
+	FLD_BALANCE_ENTRY_KEY  = "balance_entry_key"  // Avoids posting the same entry twice
+	FLD_BALANCE_YEAR       = "balance_year"
+	FLD_BALANCE_DAYS       = "balance_days" // Positive for credits, negative for debits
+	FLD_BALANCE_ENTRIES    = "balance_entries"
+	FLD_BALANCE            = "balance"
+	FLD_CREDITED           = "credited"
+	FLD_DEBITED            = "debited"
+	FLD_ACCRUAL_MONTH      = "accrual_month"
+	FLD_POSTED_COUNT       = "posted_count"
+
+	// Comp-off credit fields
+	FLD_WORK_DATE     = "work_date"     // Holiday or weekly off the staff worked on
+	FLD_EXPIRES_ON    = "expires_on"    // Last date (YYYY-MM-DD) to avail the credit
+	FLD_COMP_OFF_DAYS = "comp_off_days" // Days credited for the attendance
+	FLD_COMP_OFFS     = "comp_offs"
+
+	// Leave Encashment Table
+	FLD_ENCASHMENT_ID      = "encashment_id"
+	FLD_ENCASHMENT_STATUS  = "encashment_status" // ENCASHMENT_STATUS_*
+	FLD_CLOSING_BALANCE    = "closing_balance"
+	FLD_CARRY_FORWARD_DAYS = "carry_forward_days"
+	FLD_ENCASHED_DAYS      = "encashed_days"
+	FLD_LAPSED_DAYS        = "lapsed_days"
+	FLD_ENCASHMENTS        = "encashments"
 
 	// Shift Table
 	FLD_SHIFT_ID                   = "shift_id"
@@ -111,15 +335,54 @@ const (
 	FLD_SHIFT_DESCRIPTION          = "shift_description"
 	FLD_TYPE_OF_WORK               = "type_of_work"
 	FLD_IS_SHIFT_ROLLOVER_NEXT_DAY = "is_shift_rollover_nextday"
+	FLD_SHIFT_BREAK_MINUTES        = "shift_break_minutes" // Unpaid break allowed in the shift
 
 	// Shift Profile Table
-	FLD_SHIFT_PROFILE_ID = "shift_profile_id"
+	FLD_SHIFT_PROFILE_ID   = "shift_profile_id"
+	FLD_WEEKLY_OFFS        = "weekly_offs"   // Array of weekday names (sunday, monday...)
+	FLD_SHIFT_PATTERN      = "shift_pattern" // Array of shift_id (empty for off) with days, repeated from pattern_start_date
+	FLD_DAYS               = "days"
+	FLD_PATTERN_START_DATE = "pattern_start_date" // YYYY-MM-DD, the staff's pattern_start_date takes precedence over the profile's
+
+	// Roster Table
+	FLD_ROSTER_ID       = "roster_id"
+	FLD_ROSTER_DATE     = "roster_date" // YYYY-MM-DD
+	FLD_IS_OFF          = "is_off"
+	FLD_IS_OVERRIDE     = "is_override" // Manually assigned, kept as it is while generating the roster
+	FLD_ROSTERS         = "rosters"
+	FLD_GENERATED_COUNT = "generated_count"
+	FLD_OVERRIDE_COUNT  = "override_count"
+	FLD_SKIPPED_STAFFS  = "skipped_staffs"
+
+	// Shift Swap Table
+	FLD_SHIFT_SWAP_ID         = "shift_swap_id"
+	FLD_SWAP_STATUS           = "swap_status" // SWAP_STATUS_*
+	FLD_COUNTERPART_STAFF_ID  = "counterpart_staff_id"
+	FLD_COUNTERPART_ROSTER_ID = "counterpart_roster_id"
+	FLD_COUNTERPART_SHIFT_ID  = "counterpart_shift_id" // Shift of the counterpart when requested
+	FLD_SWAP_HISTORY          = "swap_history"
+
+	// Shift Swap Service props
+	FLD_MIN_REST_HOURS         = "min_rest_hours"         // Minimum hours between the shifts of the staff
+	FLD_MAX_CONSECUTIVE_SHIFTS = "max_consecutive_shifts" // Maximum days the staff works in a row
 
 	// Work Location Table
 	FLD_WORKLOCATION_ID          = "work_location_id"
 	FLD_WORKLOCATION_NAME        = "work_location_name"
 	FLD_WORKLOCATION_DESCRIPTION = "work_location_description"
 	FLD_WORKLOCATION             = "work_location"
+	FLD_GEOFENCE_RADIUS          = "geofence_radius"  // Radius in meters around latitude/longitude
+	FLD_GEOFENCE_POLYGON         = "geofence_polygon" // Array of latitude/longitude points
+	FLD_GEOFENCE_ACTION          = "geofence_action"  // GEOFENCE_ACTION_REJECT or GEOFENCE_ACTION_FLAG
+
+	// Kiosk mode fields of the work location
+	FLD_KIOSK_SECRET           = "kiosk_secret"         // Server generated secret of the rotating kiosk token
+	FLD_KIOSK_TOKEN_INTERVAL   = "kiosk_token_interval" // Seconds each kiosk token is valid for
+	FLD_KIOSK_REQUIRED         = "kiosk_required"       // Clock-In at the work location only with the kiosk token
+	FLD_KIOSK_TOKEN            = "kiosk_token"
+	FLD_KIOSK_TOKEN_EXPIRES_AT = "kiosk_token_expires_at"
+	FLD_KIOSK_QR_PAYLOAD       = "kiosk_qr_payload" // Content to be rendered as QR code at the kiosk
+	FLD_KIOSK_TOKEN_STEP       = "kiosk_token_step" // Time step of the kiosk token used for the punch
 
 	//Clients Table
 	FLD_CLIENT_ID          = "client_id"
@@ -137,6 +400,140 @@ const (
 	FLD_OVERTIME_DESCRIPTION = "overtime_description"
 )
 
+// Geofence actions
+const (
+	GEOFENCE_ACTION_REJECT = "reject"
+	GEOFENCE_ACTION_FLAG   = "flag"
+)
+
+// Open attendance session policies on Clock-In
+const (
+	OPEN_SESSION_POLICY_REJECT     = "reject"
+	OPEN_SESSION_POLICY_AUTO_CLOSE = "auto_close"
+)
+
+// Attendance regularization status
+const (
+	REGULARIZATION_STATUS_PENDING  = "pending"
+	REGULARIZATION_STATUS_APPROVED = "approved"
+	REGULARIZATION_STATUS_REJECTED = "rejected"
+)
+
+// Daily attendance status
+const (
+	ATTENDANCE_STATUS_PRESENT    = "present"
+	ATTENDANCE_STATUS_HALF_DAY   = "half_day"
+	ATTENDANCE_STATUS_ABSENT     = "absent"
+	ATTENDANCE_STATUS_ON_LEAVE   = "on_leave"
+	ATTENDANCE_STATUS_HOLIDAY    = "holiday"
+	ATTENDANCE_STATUS_WEEKLY_OFF = "weekly_off"
+)
+
+// Punch log formats
+const (
+	PUNCH_LOG_FORMAT_CSV = "csv"
+	PUNCH_LOG_FORMAT_DAT = "dat"
+)
+
+// Punch sources
+const (
+	PUNCH_SOURCE_DEVICE_IMPORT = "device_import"
+	PUNCH_SOURCE_KIOSK         = "kiosk"
+)
+
+// Punch log import status of each row
+const (
+	IMPORT_STATUS_CLOCK_IN  = "clock_in"
+	IMPORT_STATUS_CLOCK_OUT = "clock_out"
+	IMPORT_STATUS_DUPLICATE = "duplicate"
+	IMPORT_STATUS_ERROR     = "error"
+)
+
+// Blob Store types
+const (
+	BLOB_STORE_TYPE_LOCALFS = "localfs"
+)
+
+// Attendance anomaly types
+const (
+	ANOMALY_TYPE_IMPOSSIBLE_TRAVEL = "impossible_travel"
+	ANOMALY_TYPE_SHARED_DEVICE     = "shared_device"
+	ANOMALY_TYPE_ODD_HOURS         = "odd_hours"
+	ANOMALY_TYPE_LONG_SESSION      = "long_session"
+)
+
+// Attendance anomaly review status
+const (
+	ANOMALY_STATUS_OPEN      = "open"
+	ANOMALY_STATUS_CONFIRMED = "confirmed"
+	ANOMALY_STATUS_DISMISSED = "dismissed"
+)
+
+// Leave status
+const (
+	LEAVE_STATUS_DRAFT     = "draft"
+	LEAVE_STATUS_PENDING   = "pending"
+	LEAVE_STATUS_APPROVED  = "approved"
+	LEAVE_STATUS_REJECTED  = "rejected"
+	LEAVE_STATUS_CANCELLED = "cancelled"
+	LEAVE_STATUS_WITHDRAWN = "withdrawn"
+)
+
+// Shift swap status
+const (
+	SWAP_STATUS_REQUESTED = "requested"
+	SWAP_STATUS_ACCEPTED  = "accepted" // Accepted by the counterpart, awaiting the approval
+	SWAP_STATUS_DECLINED  = "declined" // Declined by the counterpart
+	SWAP_STATUS_APPROVED  = "approved"
+	SWAP_STATUS_REJECTED  = "rejected"
+	SWAP_STATUS_CANCELLED = "cancelled"
+)
+
+// Leave units
+const (
+	LEAVE_UNIT_FULL_DAY   = "full_day"
+	LEAVE_UNIT_HALF_DAY   = "half_day"
+	LEAVE_UNIT_HOURLY     = "hourly"
+	LEAVE_UNIT_PERMISSION = "permission" // Short absence within the shift, not charged to the balance
+)
+
+// Actions when the leave drops the team below the minimum coverage
+const (
+	COVERAGE_ACTION_WARN  = "warn"
+	COVERAGE_ACTION_BLOCK = "block"
+)
+
+// Leave approvers in the approval chain
+const (
+	APPROVER_REPORTING_MANAGER = "reporting_manager"
+	APPROVER_DEPARTMENT_HEAD   = "department_head"
+)
+
+// Leave balance entry types
+const (
+	BALANCE_ENTRY_QUOTA           = "quota"
+	BALANCE_ENTRY_ACCRUAL         = "accrual"
+	BALANCE_ENTRY_DEBIT           = "debit"
+	BALANCE_ENTRY_REVERSAL        = "reversal"
+	BALANCE_ENTRY_CARRY_FORWARD   = "carry_forward"
+	BALANCE_ENTRY_CARRY_OUT       = "carry_forward_out" // Debit in the closed year for the carry forward
+	BALANCE_ENTRY_ENCASHMENT      = "encashment"
+	BALANCE_ENTRY_LAPSE           = "lapse"
+	BALANCE_ENTRY_COMP_OFF        = "comp_off"
+	BALANCE_ENTRY_COMP_OFF_EXPIRY = "comp_off_expiry"
+)
+
+// Leave encashment status
+const (
+	ENCASHMENT_STATUS_PENDING = "pending" // To be paid by the payroll
+	ENCASHMENT_STATUS_PAID    = "paid"
+)
+
+// Error codes returned for the specific failures
+const (
+	ERROR_CODE_UNREGISTERED_DEVICE = "S30110" // Punch from the device not registered for the staff
+)
+
 func init() {
 	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
 
@@ -0,0 +1,101 @@
+package hr_common
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/zapscloud/golib-utils/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetMemberDataFloat - Get the member value as float64, integer values are converted
+func GetMemberDataFloat(data utils.Map, memberName string) (float64, error) {
+
+	dataVal, err := utils.GetMemberData(data, memberName)
+	if err != nil {
+		return 0, err
+	}
+
+	retVal, ok := ToFloat(dataVal)
+	if !ok {
+		err = &utils.AppError{ErrorStatus: 400, ErrorMsg: "Invalid Datatype", ErrorDetail: memberName + " value should be a number"}
+		return 0, err
+	}
+
+	return retVal, nil
+}
+
+// ToFloat - Convert the numeric value to float64
+func ToFloat(dataVal any) (float64, bool) {
+	switch val := dataVal.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	}
+	return 0, false
+}
+
+// ToMap - Convert the value to utils.Map, values received from the client
+// are map[string]interface{} whereas values read from MongoDB are utils.Map
+func ToMap(dataVal any) (utils.Map, bool) {
+	switch val := dataVal.(type) {
+	case utils.Map:
+		return val, true
+	case map[string]interface{}:
+		return utils.Map(val), true
+	case primitive.M:
+		return utils.Map(val), true
+	}
+	return nil, false
+}
+
+// ToArray - Convert the value to []any, values received from the client
+// are []interface{} whereas values read from MongoDB are primitive.A
+func ToArray(dataVal any) ([]any, bool) {
+	switch val := dataVal.(type) {
+	case []any:
+		return val, true
+	case primitive.A:
+		return []any(val), true
+	case []string:
+		retVal := []any{}
+		for _, item := range val {
+			retVal = append(retVal, item)
+		}
+		return retVal, true
+	case []utils.Map:
+		retVal := []any{}
+		for _, item := range val {
+			retVal = append(retVal, item)
+		}
+		return retVal, true
+	}
+	return nil, false
+}
+
+// DecodeBase64Data - Decode the base64 data, the data URL prefix (data:image/jpeg;base64,) is optional.
+// Content type is always detected from the data, the type declared in the prefix is not trusted
+func DecodeBase64Data(value string) ([]byte, string, bool) {
+
+	if strings.HasPrefix(value, "data:") {
+		index := strings.Index(value, ",")
+		if index < 0 {
+			return nil, "", false
+		}
+		value = value[index+1:]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, "", false
+	}
+	return data, http.DetectContentType(data), true
+}
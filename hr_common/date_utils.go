@@ -0,0 +1,84 @@
+package hr_common
+
+import (
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-utils/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetTimezoneLocation - Get the location of the IANA zone kept in the timezone field, UTC when not available
+func GetTimezoneLocation(data utils.Map) *time.Location {
+
+	timezone, err := utils.GetMemberDataStr(data, FLD_TIMEZONE)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ToDateTime - Convert the stored date_time value to time in the given location, the value
+// is either BSON date or the legacy local string (YYYY-MM-DD HH:MM:SS / YYYY-MM-DD) in that location
+func ToDateTime(dataVal any, loc *time.Location) (time.Time, bool) {
+
+	switch val := dataVal.(type) {
+	case time.Time:
+		return val.In(loc), true
+	case primitive.DateTime:
+		return val.Time().In(loc), true
+	case string:
+		dateTime, err := time.ParseInLocation(time.DateTime, val, loc)
+		if err != nil {
+			dateTime, err = time.ParseInLocation(time.DateOnly, val, loc)
+		}
+		if err != nil {
+			return time.Time{}, false
+		}
+		return dateTime, true
+	}
+	return time.Time{}, false
+}
+
+// ToLocalDateTimeStr - Render the stored date_time value as local string (YYYY-MM-DD HH:MM:SS)
+func ToLocalDateTimeStr(dataVal any, loc *time.Location) (string, bool) {
+
+	dateTime, ok := ToDateTime(dataVal, loc)
+	if !ok {
+		return "", false
+	}
+	return dateTime.Format(time.DateTime), true
+}
+
+// ToDateFilter - Format the time as Extended JSON date to be used in the filters
+func ToDateFilter(dateTime time.Time) string {
+	return `{"$date":"` + dateTime.UTC().Format(time.RFC3339) + `"}`
+}
+
+// ParseWeekday - Parse the weekday name (sunday, Mon...) or number (0 - Sunday to 6 - Saturday)
+func ParseWeekday(dataVal any) (time.Weekday, bool) {
+
+	if dayNum, ok := ToFloat(dataVal); ok {
+		if dayNum < 0 || dayNum > 6 {
+			return time.Sunday, false
+		}
+		return time.Weekday(int(dayNum)), true
+	}
+
+	dayName, ok := dataVal.(string)
+	if !ok || len(dayName) < 3 {
+		return time.Sunday, false
+	}
+
+	dayName = strings.ToLower(dayName)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.HasPrefix(strings.ToLower(weekday.String()), dayName) {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
@@ -0,0 +1,93 @@
+package hr_common
+
+import (
+	"math"
+
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// Mean radius of the earth in meters
+const EarthRadiusInMeters = 6371000.0
+
+// GeoPoint - Latitude & Longitude pair
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// GetGeoPoint - Get the latitude & longitude from the given data
+func GetGeoPoint(data utils.Map) (GeoPoint, error) {
+
+	latitude, err := GetMemberDataFloat(data, FLD_LATITUDE)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+
+	longitude, err := GetMemberDataFloat(data, FLD_LONGITUDE)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		err = &utils.AppError{ErrorStatus: 400, ErrorMsg: "Invalid Coordinates", ErrorDetail: "latitude/longitude value is out of range"}
+		return GeoPoint{}, err
+	}
+
+	return GeoPoint{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// GetGeoPolygon - Get the polygon points from the given value, each point
+// should be an object with latitude & longitude
+func GetGeoPolygon(dataVal any) ([]GeoPoint, error) {
+
+	errPolygon := &utils.AppError{ErrorStatus: 400, ErrorMsg: "Invalid Polygon", ErrorDetail: "Polygon should have minimum 3 points with latitude/longitude"}
+
+	points, ok := ToArray(dataVal)
+	if !ok || len(points) < 3 {
+		return nil, errPolygon
+	}
+
+	polygon := []GeoPoint{}
+	for _, point := range points {
+		pointData, ok := ToMap(point)
+		if !ok {
+			return nil, errPolygon
+		}
+		geoPoint, err := GetGeoPoint(pointData)
+		if err != nil {
+			return nil, errPolygon
+		}
+		polygon = append(polygon, geoPoint)
+	}
+
+	return polygon, nil
+}
+
+// GetDistanceInMeters - Great-circle distance between two points (Haversine formula)
+func GetDistanceInMeters(from GeoPoint, to GeoPoint) float64 {
+
+	fromLat := from.Latitude * math.Pi / 180
+	toLat := to.Latitude * math.Pi / 180
+	deltaLat := (to.Latitude - from.Latitude) * math.Pi / 180
+	deltaLng := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(fromLat)*math.Cos(toLat)*math.Sin(deltaLng/2)*math.Sin(deltaLng/2)
+
+	return EarthRadiusInMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// IsPointInPolygon - Check whether the point lies inside the polygon (Ray casting)
+func IsPointInPolygon(point GeoPoint, polygon []GeoPoint) bool {
+
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		pi := polygon[i]
+		pj := polygon[j]
+		if (pi.Latitude > point.Latitude) != (pj.Latitude > point.Latitude) &&
+			point.Longitude < (pj.Longitude-pi.Longitude)*(point.Latitude-pi.Latitude)/(pj.Latitude-pi.Latitude)+pi.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
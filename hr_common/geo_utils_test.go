@@ -0,0 +1,91 @@
+package hr_common
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetDistanceInMeters(t *testing.T) {
+
+	tests := []struct {
+		name string
+		from GeoPoint
+		to   GeoPoint
+		want float64
+	}{
+		{
+			name: "same point",
+			from: GeoPoint{Latitude: 13.0827, Longitude: 80.2707},
+			to:   GeoPoint{Latitude: 13.0827, Longitude: 80.2707},
+			want: 0,
+		},
+		{
+			name: "one degree of latitude",
+			from: GeoPoint{Latitude: 0, Longitude: 0},
+			to:   GeoPoint{Latitude: 1, Longitude: 0},
+			want: 111195,
+		},
+		{
+			name: "one degree of longitude at equator",
+			from: GeoPoint{Latitude: 0, Longitude: 179.5},
+			to:   GeoPoint{Latitude: 0, Longitude: -179.5},
+			want: 111195,
+		},
+		{
+			name: "chennai to bengaluru",
+			from: GeoPoint{Latitude: 13.0827, Longitude: 80.2707},
+			to:   GeoPoint{Latitude: 12.9716, Longitude: 77.5946},
+			want: 290170,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			distance := GetDistanceInMeters(test.from, test.to)
+			if math.Abs(distance-test.want) > test.want*0.001+1 {
+				t.Errorf("distance = %.0f, want %.0f", distance, test.want)
+			}
+		})
+	}
+}
+
+func TestIsPointInPolygon(t *testing.T) {
+
+	square := []GeoPoint{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 10},
+		{Latitude: 10, Longitude: 10},
+		{Latitude: 10, Longitude: 0},
+	}
+	// L shaped polygon with the notch at the top right
+	concave := []GeoPoint{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 10},
+		{Latitude: 5, Longitude: 10},
+		{Latitude: 5, Longitude: 5},
+		{Latitude: 10, Longitude: 5},
+		{Latitude: 10, Longitude: 0},
+	}
+
+	tests := []struct {
+		name    string
+		point   GeoPoint
+		polygon []GeoPoint
+		want    bool
+	}{
+		{name: "inside", point: GeoPoint{Latitude: 5, Longitude: 5}, polygon: square, want: true},
+		{name: "outside", point: GeoPoint{Latitude: 15, Longitude: 5}, polygon: square, want: false},
+		{name: "outside on the ray", point: GeoPoint{Latitude: 5, Longitude: -1}, polygon: square, want: false},
+		{name: "inside concave", point: GeoPoint{Latitude: 2, Longitude: 8}, polygon: concave, want: true},
+		{name: "in the notch of concave", point: GeoPoint{Latitude: 8, Longitude: 8}, polygon: concave, want: false},
+		{name: "empty polygon", point: GeoPoint{Latitude: 5, Longitude: 5}, polygon: []GeoPoint{}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if inside := IsPointInPolygon(test.point, test.polygon); inside != test.want {
+				t.Errorf("inside = %v, want %v", inside, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package hr_common
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// Date-Time layouts accepted in the device exports
+var punchLogDateTimeLayouts = []string{
+	time.DateTime,
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04",
+}
+
+// PunchLogEntry - Punch read from the biometric device export
+type PunchLogEntry struct {
+	Row          int
+	DeviceUserId string
+	DeviceId     string
+	DateTime     time.Time
+	Error        string
+}
+
+// ParsePunchLog - Parse the punch log exported from the biometric device, the date-time values
+// are in the given location. Rows which can not be parsed are returned with the Error
+func ParsePunchLog(format string, content string, loc *time.Location) ([]PunchLogEntry, error) {
+
+	switch strings.ToLower(format) {
+	case PUNCH_LOG_FORMAT_CSV:
+		return parsePunchLogCsv(content, loc)
+	case PUNCH_LOG_FORMAT_DAT:
+		return parsePunchLogDat(content, loc), nil
+	}
+
+	err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Format", ErrorDetail: "Punch log format should be csv or dat"}
+	return nil, err
+}
+
+// parsePunchLogCsv - Parse the CSV with the header row having device_user_id, date_time and optional device_id
+func parsePunchLogCsv(content string, loc *time.Location) ([]PunchLogEntry, error) {
+
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Punch Log", ErrorDetail: "CSV header row is missing"}
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for index, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = index
+	}
+	userIdCol, userIdOk := columns[FLD_DEVICE_USER_ID]
+	dateTimeCol, dateTimeOk := columns[FLD_DATETIME]
+	if !userIdOk || !dateTimeOk {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Punch Log", ErrorDetail: "CSV header should have device_user_id and date_time columns"}
+		return nil, err
+	}
+	deviceIdCol, deviceIdOk := columns[FLD_DEVICE_ID]
+
+	entries := []PunchLogEntry{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			entry := PunchLogEntry{Error: err.Error()}
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				entry.Row = parseErr.StartLine
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		// Line number of the record in the content
+		row, _ := reader.FieldPos(0)
+		entry := PunchLogEntry{Row: row}
+		if len(record) == 1 && len(strings.TrimSpace(record[0])) == 0 {
+			// Line with spaces only
+			continue
+		}
+		if userIdCol >= len(record) || dateTimeCol >= len(record) {
+			entry.Error = "Missing device_user_id or date_time"
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry.DeviceUserId = strings.TrimSpace(record[userIdCol])
+		if deviceIdOk && deviceIdCol < len(record) {
+			entry.DeviceId = strings.TrimSpace(record[deviceIdCol])
+		}
+		entry.DateTime, entry.Error = parsePunchLogDateTime(record[dateTimeCol], loc)
+		if len(entry.DeviceUserId) == 0 && len(entry.Error) == 0 {
+			entry.Error = "Missing device_user_id"
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// parsePunchLogDat - Parse the ZKTeco style attendance log, each line has the device user id,
+// date, time followed by the verify mode & punch state columns which are ignored
+func parsePunchLogDat(content string, loc *time.Location) []PunchLogEntry {
+
+	entries := []PunchLogEntry{}
+	for index, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			// Empty line
+			continue
+		}
+
+		entry := PunchLogEntry{Row: index + 1}
+		if len(fields) < 3 {
+			entry.Error = "Missing device_user_id or date_time"
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry.DeviceUserId = fields[0]
+		entry.DateTime, entry.Error = parsePunchLogDateTime(fields[1]+" "+fields[2], loc)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func parsePunchLogDateTime(value string, loc *time.Location) (time.Time, string) {
+
+	value = strings.TrimSpace(value)
+	for _, layout := range punchLogDateTimeLayouts {
+		dateTime, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return dateTime, ""
+		}
+	}
+	return time.Time{}, "Invalid date_time " + value
+}
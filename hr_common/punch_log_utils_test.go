@@ -0,0 +1,101 @@
+package hr_common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePunchLog(t *testing.T) {
+
+	loc := time.FixedZone("IST", 19800)
+
+	type punch struct {
+		row          int
+		deviceUserId string
+		deviceId     string
+		dateTime     string
+		hasError     bool
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		wantErr bool
+		want    []punch
+	}{
+		{
+			name:    "invalid format",
+			format:  "xlsx",
+			content: "",
+			wantErr: true,
+		},
+		{
+			name:    "csv without header columns",
+			format:  PUNCH_LOG_FORMAT_CSV,
+			content: "user,time\n101,2025-03-10 09:00:00\n",
+			wantErr: true,
+		},
+		{
+			name:    "csv rows with device id and layouts",
+			format:  "CSV",
+			content: "Device_User_Id, Date_Time, Device_Id\n101, 2025-03-10 09:00:00, D1\n102,2025/03/10 18:05,D2\n",
+			want: []punch{
+				{row: 2, deviceUserId: "101", deviceId: "D1", dateTime: "2025-03-10 09:00:00"},
+				{row: 3, deviceUserId: "102", deviceId: "D2", dateTime: "2025-03-10 18:05:00"},
+			},
+		},
+		{
+			name:    "csv invalid rows are returned with the error",
+			format:  PUNCH_LOG_FORMAT_CSV,
+			content: "device_user_id,date_time\n101,10-03-2025\n,2025-03-10 09:00:00\n103\n",
+			want: []punch{
+				{row: 2, deviceUserId: "101", hasError: true},
+				{row: 3, hasError: true},
+				{row: 4, hasError: true},
+			},
+		},
+		{
+			name:    "dat rows skip the empty lines",
+			format:  PUNCH_LOG_FORMAT_DAT,
+			content: "  101\t2025-03-10 09:00:00\t1\t0\n\n102 2025-03-10\n",
+			want: []punch{
+				{row: 1, deviceUserId: "101", dateTime: "2025-03-10 09:00:00"},
+				{row: 3, hasError: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := ParsePunchLog(test.format, test.content, loc)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if len(entries) != len(test.want) {
+				t.Fatalf("entries = %d, want %d", len(entries), len(test.want))
+			}
+
+			for index, want := range test.want {
+				entry := entries[index]
+				if entry.Row != want.row {
+					t.Errorf("entry %d row = %d, want %d", index, entry.Row, want.row)
+				}
+				if entry.DeviceUserId != want.deviceUserId {
+					t.Errorf("entry %d device_user_id = %q, want %q", index, entry.DeviceUserId, want.deviceUserId)
+				}
+				if entry.DeviceId != want.deviceId {
+					t.Errorf("entry %d device_id = %q, want %q", index, entry.DeviceId, want.deviceId)
+				}
+				if (len(entry.Error) > 0) != want.hasError {
+					t.Errorf("entry %d error = %q, want error %v", index, entry.Error, want.hasError)
+				}
+				if len(want.dateTime) > 0 {
+					if entry.DateTime.Location() != loc || entry.DateTime.Format(time.DateTime) != want.dateTime {
+						t.Errorf("entry %d date_time = %v, want %s IST", index, entry.DateTime, want.dateTime)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package hr_common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// Length of the generated secret in bytes and the digits in the token
+const (
+	TotpSecretSize = 20
+	TotpDigits     = 6
+)
+
+// GenerateTotpSecret - Generate a random secret encoded in base32
+func GenerateTotpSecret() (string, error) {
+
+	secret := make([]byte, TotpSecretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Secret Generation Failed", ErrorDetail: err.Error()}
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
+}
+
+// GenerateTotp - Generate the time based one time password (RFC 6238) of the secret for the time step
+// which the given time falls in, the step also returned to know when the token expires
+func GenerateTotp(secret string, atTime time.Time, step time.Duration) (string, time.Time, error) {
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Secret", ErrorDetail: "Secret should be base32 encoded"}
+		return "", time.Time{}, err
+	}
+
+	counter := atTime.Unix() / int64(step.Seconds())
+	stepStart := time.Unix(counter*int64(step.Seconds()), 0).UTC()
+	return computeHotp(key, uint64(counter)), stepStart, nil
+}
+
+// ValidateTotp - Verify the token against the secret for the time step of the given time, tokens
+// of the adjacent steps within the skew are accepted to allow the clock drift & the scan delay
+func ValidateTotp(secret string, token string, atTime time.Time, step time.Duration, skew int) bool {
+
+	_, ok := MatchTotpStep(secret, token, atTime, step, skew)
+	return ok
+}
+
+// MatchTotpStep - Same as ValidateTotp, also returns the counter of the time step the token belongs to
+// so that the use of the token can be tracked
+func MatchTotpStep(secret string, token string, atTime time.Time, step time.Duration, skew int) (int64, bool) {
+
+	for offset := -skew; offset <= skew; offset++ {
+		stepTime := atTime.Add(time.Duration(offset) * step)
+		expected, _, err := GenerateTotp(secret, stepTime, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(strings.TrimSpace(token))) {
+			return stepTime.Unix() / int64(step.Seconds()), true
+		}
+	}
+	return 0, false
+}
+
+// computeHotp - HMAC based one time password (RFC 4226) of the counter
+func computeHotp(key []byte, counter uint64) string {
+
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for index := 0; index < TotpDigits; index++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TotpDigits, code%modulo)
+}
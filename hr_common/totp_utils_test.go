@@ -0,0 +1,112 @@
+package hr_common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchTotpStep(t *testing.T) {
+
+	// RFC 6238 test secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	step := 30 * time.Second
+	issuedAt := time.Unix(1111111109, 0)
+
+	token, stepStart, err := GenerateTotp(secret, issuedAt, step)
+	if err != nil {
+		t.Fatalf("GenerateTotp error = %v", err)
+	}
+	if token != "081804" {
+		t.Fatalf("GenerateTotp token = %q, want %q", token, "081804")
+	}
+	if !stepStart.Equal(time.Unix(1111111080, 0)) {
+		t.Fatalf("GenerateTotp step start = %v, want %v", stepStart, time.Unix(1111111080, 0))
+	}
+
+	tests := []struct {
+		name        string
+		secret      string
+		token       string
+		atTime      time.Time
+		skew        int
+		wantCounter int64
+		wantOk      bool
+	}{
+		{
+			name:        "same step",
+			secret:      secret,
+			token:       token,
+			atTime:      issuedAt,
+			wantCounter: 37037036,
+			wantOk:      true,
+		},
+		{
+			name:        "token with spaces",
+			secret:      secret,
+			token:       " " + token + " ",
+			atTime:      issuedAt,
+			wantCounter: 37037036,
+			wantOk:      true,
+		},
+		{
+			name:   "next step without skew",
+			secret: secret,
+			token:  token,
+			atTime: issuedAt.Add(step),
+		},
+		{
+			name:        "next step within skew",
+			secret:      secret,
+			token:       token,
+			atTime:      issuedAt.Add(step),
+			skew:        1,
+			wantCounter: 37037036,
+			wantOk:      true,
+		},
+		{
+			name:        "previous step within skew",
+			secret:      secret,
+			token:       token,
+			atTime:      issuedAt.Add(-step),
+			skew:        1,
+			wantCounter: 37037036,
+			wantOk:      true,
+		},
+		{
+			name:   "beyond skew",
+			secret: secret,
+			token:  token,
+			atTime: issuedAt.Add(2 * step),
+			skew:   1,
+		},
+		{
+			name:   "wrong token",
+			secret: secret,
+			token:  "000000",
+			atTime: issuedAt,
+			skew:   1,
+		},
+		{
+			name:   "invalid secret",
+			secret: "not-base32!",
+			token:  token,
+			atTime: issuedAt,
+			skew:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter, ok := MatchTotpStep(test.secret, test.token, test.atTime, step, test.skew)
+			if ok != test.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, test.wantOk)
+			}
+			if counter != test.wantCounter {
+				t.Errorf("counter = %d, want %d", counter, test.wantCounter)
+			}
+			if ValidateTotp(test.secret, test.token, test.atTime, step, test.skew) != test.wantOk {
+				t.Errorf("ValidateTotp = %v, want %v", !test.wantOk, test.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// AnomalyDao - Attendance Anomaly DAO Repository
+type AnomalyDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Attendance Anomaly Details
+	Get(anomalyId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Attendance Anomaly
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(anomalyId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(anomalyId string) (int64, error)
+}
+
+// NewAnomalyDao - Contruct Attendance Anomaly Dao
+func NewAnomalyDao(client utils.Map, businessId string, staffId string) AnomalyDao {
+	var daoAnomaly AnomalyDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoAnomaly = &mongodb_repository.AnomalyMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoAnomaly != nil {
+		// Initialize the Dao
+		daoAnomaly.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoAnomaly
+}
@@ -0,0 +1,45 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-hr/hr_repository/localfs_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// BlobStore - Storage for the binary objects like photos & attachments
+type BlobStore interface {
+	// InitializeStore
+	InitializeStore(props utils.Map, businessId string) error
+
+	// Put - Store the object
+	Put(objectRef string, data []byte) error
+
+	// Get - Get the object
+	Get(objectRef string) ([]byte, error)
+
+	// Delete - Delete the object
+	Delete(objectRef string) error
+}
+
+// NewBlobStore - Construct BlobStore based on the blob_store_type in props
+func NewBlobStore(props utils.Map, businessId string) (BlobStore, error) {
+	var blobStore BlobStore = nil
+
+	storeType, _ := utils.GetMemberDataStr(props, hr_common.FLD_BLOB_STORE_TYPE)
+
+	switch storeType {
+	case hr_common.BLOB_STORE_TYPE_LOCALFS:
+		blobStore = &localfs_repository.LocalFSBlobStore{}
+	default:
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid blob_store_type", ErrorDetail: "Given blob_store_type is not supported"}
+		return nil, err
+	}
+
+	// Initialize the Store
+	err := blobStore.InitializeStore(props, businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	return blobStore, nil
+}
@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// LeaveBalanceDao - Leave Balance DAO Repository
+type LeaveBalanceDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Leave Balance Details
+	Get(balanceEntryId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Leave Balance
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(balanceEntryId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(balanceEntryId string) (int64, error)
+}
+
+// NewLeaveBalanceDao - Contruct Leave Balance Dao
+func NewLeaveBalanceDao(client utils.Map, businessId string, staffId string) LeaveBalanceDao {
+	var daoLeaveBalance LeaveBalanceDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoLeaveBalance = &mongodb_repository.LeaveBalanceMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoLeaveBalance != nil {
+		// Initialize the Dao
+		daoLeaveBalance.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoLeaveBalance
+}
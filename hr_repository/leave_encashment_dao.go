@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// LeaveEncashmentDao - Leave Encashment DAO Repository
+type LeaveEncashmentDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Leave Encashment Details
+	Get(encashmentId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Leave Encashment
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(encashmentId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(encashmentId string) (int64, error)
+}
+
+// NewLeaveEncashmentDao - Contruct Leave Encashment Dao
+func NewLeaveEncashmentDao(client utils.Map, businessId string, staffId string) LeaveEncashmentDao {
+	var daoLeaveEncashment LeaveEncashmentDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoLeaveEncashment = &mongodb_repository.LeaveEncashmentMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoLeaveEncashment != nil {
+		// Initialize the Dao
+		daoLeaveEncashment.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoLeaveEncashment
+}
@@ -0,0 +1,59 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// LeavePolicyDao - LeavePolicy DAO Repository
+type LeavePolicyDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get LeavePolicy Details
+	Get(leavePolicyId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create LeavePolicy
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(leavePolicyId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(leavePolicyId string) (int64, error)
+
+	// DeleteAll - DeleteAll Collection
+	DeleteAll() (int64, error)
+}
+
+// NewLeavePolicyDao - Contruct LeavePolicy Dao
+func NewLeavePolicyDao(client utils.Map, business_id string) LeavePolicyDao {
+	var daoLeavePolicy LeavePolicyDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoLeavePolicy = &mongodb_repository.LeavePolicyMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoLeavePolicy != nil {
+		// Initialize the Dao
+		daoLeavePolicy.InitializeDao(client, business_id)
+	}
+
+	return daoLeavePolicy
+}
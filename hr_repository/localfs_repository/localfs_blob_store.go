@@ -0,0 +1,115 @@
+package localfs_repository
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// LocalFSBlobStore - BlobStore on the local filesystem, objects of the
+// business are kept under <blob_store_path>/<business_id>
+type LocalFSBlobStore struct {
+	basePath string
+}
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
+}
+
+func (p *LocalFSBlobStore) InitializeStore(props utils.Map, businessId string) error {
+	log.Println("Initialize LocalFS BlobStore")
+
+	storePath, err := utils.GetMemberDataStr(props, hr_common.FLD_BLOB_STORE_PATH)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No blob_store_path", ErrorDetail: "blob_store_path should be sent for local filesystem store"}
+		return err
+	}
+
+	p.basePath, err = p.getSafePath(filepath.Clean(storePath), businessId)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(p.basePath, 0750)
+}
+
+// Put - Store the object
+func (p *LocalFSBlobStore) Put(objectRef string, data []byte) error {
+
+	log.Println("LocalFSBlobStore::Put - Begin", objectRef, len(data))
+
+	filePath, err := p.getSafePath(p.basePath, objectRef)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0750)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filePath, data, 0640)
+	log.Println("LocalFSBlobStore::Put - End", err)
+	return err
+}
+
+// Get - Get the object
+func (p *LocalFSBlobStore) Get(objectRef string) ([]byte, error) {
+
+	log.Println("LocalFSBlobStore::Get - Begin", objectRef)
+
+	filePath, err := p.getSafePath(p.basePath, objectRef)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Object not found", ErrorDetail: "No such object " + objectRef}
+		return nil, err
+	}
+
+	log.Println("LocalFSBlobStore::Get - End", len(data))
+	return data, nil
+}
+
+// Delete - Delete the object
+func (p *LocalFSBlobStore) Delete(objectRef string) error {
+
+	log.Println("LocalFSBlobStore::Delete - Begin", objectRef)
+
+	filePath, err := p.getSafePath(p.basePath, objectRef)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filePath)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	log.Println("LocalFSBlobStore::Delete - End", err)
+	return err
+}
+
+// getSafePath - Join the relative path to the base path, the path should not go out of the base path
+func (p *LocalFSBlobStore) getSafePath(basePath string, relPath string) (string, error) {
+
+	errPath := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid object reference", ErrorDetail: "Object reference should be a relative path"}
+
+	if len(relPath) == 0 || filepath.IsAbs(relPath) {
+		return "", errPath
+	}
+
+	fullPath := filepath.Join(basePath, relPath)
+	rel, err := filepath.Rel(basePath, fullPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errPath
+	}
+
+	return fullPath, nil
+}
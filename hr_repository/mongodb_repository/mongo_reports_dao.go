@@ -83,6 +83,23 @@ func (p *ReportsMongoDBDao) GetAttendanceSummary(filter string, aggr string, sor
 	stages = append(stages, matchStage)
 	// ==================================================
 
+	// Expected shift of the Clock-In =====================
+	// Rostered punches refer the shift in shift_id, the others in type_of_work
+	clockInPath := "$" + hr_common.FLD_CLOCK_IN + "."
+	shiftStage := bson.M{
+		db_common.MONGODB_SET: bson.M{
+			hr_common.FLD_CLOCK_IN + "." + hr_common.FLD_SHIFT_ID: bson.M{
+				"$cond": bson.A{
+					bson.M{"$ifNull": bson.A{clockInPath + hr_common.FLD_ROSTER_ID, false}},
+					clockInPath + hr_common.FLD_SHIFT_ID,
+					clockInPath + hr_common.FLD_TYPE_OF_WORK,
+				},
+			},
+		},
+	}
+	stages = append(stages, shiftStage)
+	// ==================================================
+
 	// // Add Group stage ================================
 	// groupbyStage := bson.M{
 	// 	db_common.MONGODB_GROUP: bson.M{
@@ -102,6 +119,9 @@ func (p *ReportsMongoDBDao) GetAttendanceSummary(filter string, aggr string, sor
 			db_common.MONGODB_GROUP: bson.M{
 				db_common.FLD_DEFAULT_ID: aggrdoc,
 				hr_common.FLD_GROUP_DOCS: bson.M{db_common.MONGODB_PUSH: db_common.MONGODB_ROOT},
+				// worked_minutes of each attendance already excludes the unpaid breaks
+				hr_common.FLD_TOTAL_WORKED_MINUTES: bson.M{db_common.MONGODB_SUM: "$" + hr_common.FLD_WORKED_MINUTES},
+				hr_common.FLD_TOTAL_BREAK_MINUTES:  bson.M{db_common.MONGODB_SUM: "$" + hr_common.FLD_BREAK_MINUTES},
 			},
 		}
 		// Add it to Aggregate Stage
@@ -216,7 +236,7 @@ func (p *ReportsMongoDBDao) appendListLookups(stages []bson.M) []bson.M {
 	lookupStage2 := bson.M{
 		db_common.MONGODB_LOOKUP: bson.M{
 			db_common.MONGODB_STR_FROM:         hr_common.DbHrShifts,
-			db_common.MONGODB_STR_LOCALFIELD:   hr_common.FLD_GROUP_DOCS + "." + hr_common.FLD_CLOCK_IN + "." + "type_of_work",
+			db_common.MONGODB_STR_LOCALFIELD:   hr_common.FLD_GROUP_DOCS + "." + hr_common.FLD_CLOCK_IN + "." + hr_common.FLD_SHIFT_ID,
 			db_common.MONGODB_STR_FOREIGNFIELD: hr_common.FLD_SHIFT_ID,
 			db_common.MONGODB_STR_AS:           hr_common.FLD_SHIFT_INFO,
 			db_common.MONGODB_STR_PIPELINE: []bson.M{
@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// RegularizationDao - Attendance Regularization DAO Repository
+type RegularizationDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Attendance Regularization Details
+	Get(regularizationId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Attendance Regularization
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(regularizationId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(regularizationId string) (int64, error)
+}
+
+// NewRegularizationDao - Contruct Attendance Regularization Dao
+func NewRegularizationDao(client utils.Map, businessId string, staffId string) RegularizationDao {
+	var daoRegularization RegularizationDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoRegularization = &mongodb_repository.RegularizationMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoRegularization != nil {
+		// Initialize the Dao
+		daoRegularization.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoRegularization
+}
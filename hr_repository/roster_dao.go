@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// RosterDao - Roster DAO Repository
+type RosterDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Roster Details
+	Get(rosterId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Roster
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(rosterId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(rosterId string) (int64, error)
+}
+
+// NewRosterDao - Contruct Roster Dao
+func NewRosterDao(client utils.Map, businessId string, staffId string) RosterDao {
+	var daoRoster RosterDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoRoster = &mongodb_repository.RosterMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoRoster != nil {
+		// Initialize the Dao
+		daoRoster.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoRoster
+}
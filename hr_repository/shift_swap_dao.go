@@ -0,0 +1,56 @@
+package hr_repository
+
+import (
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-hr/hr_repository/mongodb_repository"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// ShiftSwapDao - Shift Swap DAO Repository
+type ShiftSwapDao interface {
+	// InitializeDao
+	InitializeDao(client utils.Map, businessId string, staffId string)
+
+	// List
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+
+	// Get - Get Shift Swap Details
+	Get(shiftSwapId string) (utils.Map, error)
+
+	// Find - Find by code
+	Find(filter string) (utils.Map, error)
+
+	// Create - Create Shift Swap
+	Create(indata utils.Map) (utils.Map, error)
+
+	// Update - Update Collection
+	Update(shiftSwapId string, indata utils.Map) (utils.Map, error)
+
+	// Delete - Delete Collection
+	Delete(shiftSwapId string) (int64, error)
+}
+
+// NewShiftSwapDao - Contruct Shift Swap Dao
+func NewShiftSwapDao(client utils.Map, businessId string, staffId string) ShiftSwapDao {
+	var daoShiftSwap ShiftSwapDao = nil
+
+	// Get DatabaseType and no need to validate error
+	// since the dbType was assigned with correct value after dbService was created
+	dbType, _ := db_common.GetDatabaseType(client)
+
+	switch dbType {
+	case db_common.DATABASE_TYPE_MONGODB:
+		daoShiftSwap = &mongodb_repository.ShiftSwapMongoDBDao{}
+	case db_common.DATABASE_TYPE_ZAPSDB:
+		// *Not Implemented yet*
+	case db_common.DATABASE_TYPE_MYSQLDB:
+		// *Not Implemented yet*
+	}
+
+	if daoShiftSwap != nil {
+		// Initialize the Dao
+		daoShiftSwap.InitializeDao(client, businessId, staffId)
+	}
+
+	return daoShiftSwap
+}
@@ -1,7 +1,13 @@
 package hr_services
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/zapscloud/golib-business/business_common"
@@ -16,8 +22,38 @@ import (
 )
 
 const (
-// // The character encoding for the email.
-// CharSet = "UTF-8"
+	// // The character encoding for the email.
+	// CharSet = "UTF-8"
+
+	// Default grace period after shift end for auto clock-out
+	DEFAULT_AUTO_CLOSE_GRACE_MINUTES = 120
+
+	// Maximum session length when the session has no shift, to auto-close the open session & pair the device punches
+	DEFAULT_MAX_SESSION_HOURS = 16
+
+	// Default worked minutes thresholds for the daily status
+	DEFAULT_HALF_DAY_MINUTES = 240
+	DEFAULT_FULL_DAY_MINUTES = 480
+
+	// Maximum days allowed in a daily status request
+	MAX_DAILY_STATUS_DAYS = 366
+
+	// Default window within which the repeated device punches are duplicates
+	DEFAULT_PUNCH_DEDUPE_MINUTES = 2
+
+	// Default maximum size of the punch photo
+	DEFAULT_MAX_PHOTO_SIZE_KB = 2048
+
+	// Default thresholds for the anomaly detection
+	DEFAULT_MAX_TRAVEL_SPEED_KMPH    = 150
+	DEFAULT_ODD_HOURS_MARGIN_MINUTES = 60
+	DEFAULT_LONG_SESSION_HOURS       = 14
+
+	// Punches closer than this distance (meters) are ignored for the travel speed, to allow GPS drift
+	MIN_TRAVEL_DISTANCE = 1000
+
+	// Default days the comp-off credit can be availed after the work date
+	DEFAULT_COMP_OFF_VALIDITY_DAYS = 90
 )
 
 // AttendanceService - Attendances Service structure
@@ -31,9 +67,21 @@ type AttendanceService interface {
 	ClockInMany(indata utils.Map) (utils.Map, error)
 	ClockOut(attendance_id string, indata utils.Map) (utils.Map, error)
 	ClockOutMany(indata utils.Map) (utils.Map, error)
+	BreakStart(attendance_id string, indata utils.Map) (utils.Map, error)
+	BreakEnd(attendance_id string, indata utils.Map) (utils.Map, error)
 	Update(attendance_id string, indata utils.Map) (utils.Map, error)
 	Delete(attendance_id string, delete_permanent bool) error
 	DeleteAll(delete_permanent bool) error
+	GetOpenSession(staffId string) (utils.Map, error)
+	AutoCloseOpenSessions(asOf time.Time) (utils.Map, error)
+	GetDailyStatus(staffId string, from string, to string) (utils.Map, error)
+	MigrateDateTimes(timezone string) (utils.Map, error)
+	ImportPunchLog(indata utils.Map) (utils.Map, error)
+	GetPhoto(attendance_id string, punchType string) (utils.Map, error)
+	DetectAnomalies(from string, to string) (utils.Map, error)
+	ListAnomalies(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	ReviewAnomaly(anomalyId string, indata utils.Map) (utils.Map, error)
+	CreditCompOffs(from string, to string) (utils.Map, error)
 
 	BeginTransaction()
 	CommitTransaction()
@@ -50,10 +98,29 @@ type attendanceBaseService struct {
 	daoPlatformBusiness platform_repository.BusinessDao
 	daoPlatformAppUser  platform_repository.AppUserDao
 	daoStaff            hr_repository.StaffDao
-
-	child      AttendanceService
-	businessId string
-	staffId    string
+	daoWorkLocation     hr_repository.WorkLocationDao
+	daoShift            hr_repository.ShiftDao
+	daoShiftProfile     hr_repository.ShiftProfileDao
+	daoRoster           hr_repository.RosterDao
+	daoHoliday          hr_repository.HolidayDao
+	blobStore           hr_repository.BlobStore
+	daoAnomaly          hr_repository.AnomalyDao
+	daoLeaveType        hr_repository.LeaveTypeDao
+	daoLeaveBalance     hr_repository.LeaveBalanceDao
+	daoLeavePolicy      hr_repository.LeavePolicyDao
+
+	child             AttendanceService
+	businessId        string
+	staffId           string
+	openSessionPolicy string
+	autoCloseGrace    time.Duration
+	halfDayMinutes    int
+	fullDayMinutes    int
+	maxPhotoSize      int
+	maxTravelSpeed    float64
+	oddHoursMargin    time.Duration
+	longSession       time.Duration
+	requireDevice     bool
 }
 
 func init() {
@@ -91,11 +158,80 @@ func NewAttendanceService(props utils.Map) (AttendanceService, error) {
 	p.businessId = businessId
 	p.staffId = staffId
 
+	// Policy for Clock-In when the staff already has an open session, this is optional parameter
+	p.openSessionPolicy, _ = utils.GetMemberDataStr(props, hr_common.FLD_OPEN_SESSION_POLICY)
+	if p.openSessionPolicy != hr_common.OPEN_SESSION_POLICY_AUTO_CLOSE {
+		p.openSessionPolicy = hr_common.OPEN_SESSION_POLICY_REJECT
+	}
+
+	// Grace period after shift end for auto clock-out, this is optional parameter
+	graceMinutes, err := utils.GetMemberDataInt(props, hr_common.FLD_AUTO_CLOSE_GRACE_MINUTES, true)
+	if err != nil || graceMinutes < 0 {
+		graceMinutes = DEFAULT_AUTO_CLOSE_GRACE_MINUTES
+	}
+	p.autoCloseGrace = time.Duration(graceMinutes) * time.Minute
+
+	// Worked minutes thresholds for half-day & present, these are optional parameters
+	p.halfDayMinutes, err = utils.GetMemberDataInt(props, hr_common.FLD_HALF_DAY_MINUTES, true)
+	if err != nil || p.halfDayMinutes <= 0 {
+		p.halfDayMinutes = DEFAULT_HALF_DAY_MINUTES
+	}
+	p.fullDayMinutes, err = utils.GetMemberDataInt(props, hr_common.FLD_FULL_DAY_MINUTES, true)
+	if err != nil || p.fullDayMinutes < p.halfDayMinutes {
+		p.fullDayMinutes = DEFAULT_FULL_DAY_MINUTES
+		if p.fullDayMinutes < p.halfDayMinutes {
+			p.fullDayMinutes = p.halfDayMinutes
+		}
+	}
+
+	// Maximum size of the punch photo, this is optional parameter
+	maxPhotoSizeKb, err := utils.GetMemberDataInt(props, hr_common.FLD_MAX_PHOTO_SIZE_KB, true)
+	if err != nil || maxPhotoSizeKb <= 0 {
+		maxPhotoSizeKb = DEFAULT_MAX_PHOTO_SIZE_KB
+	}
+	p.maxPhotoSize = maxPhotoSizeKb * 1024
+
+	// Thresholds for the anomaly detection, these are optional parameters
+	p.maxTravelSpeed, err = hr_common.GetMemberDataFloat(props, hr_common.FLD_MAX_TRAVEL_SPEED_KMPH)
+	if err != nil || p.maxTravelSpeed <= 0 {
+		p.maxTravelSpeed = DEFAULT_MAX_TRAVEL_SPEED_KMPH
+	}
+	oddHoursMargin, err := utils.GetMemberDataInt(props, hr_common.FLD_ODD_HOURS_MARGIN_MINUTES, true)
+	if err != nil || oddHoursMargin < 0 {
+		oddHoursMargin = DEFAULT_ODD_HOURS_MARGIN_MINUTES
+	}
+	p.oddHoursMargin = time.Duration(oddHoursMargin) * time.Minute
+	longSessionHours, err := utils.GetMemberDataInt(props, hr_common.FLD_LONG_SESSION_HOURS, true)
+	if err != nil || longSessionHours <= 0 {
+		longSessionHours = DEFAULT_LONG_SESSION_HOURS
+	}
+	p.longSession = time.Duration(longSessionHours) * time.Hour
+
+	// Punches of the staff without registered devices are rejected when enabled
+	p.requireDevice, _ = utils.GetMemberDataBool(props, hr_common.FLD_REQUIRE_DEVICE_BINDING)
+
 	// Initialize services
 	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
 	p.daoPlatformAppUser = platform_repository.NewAppUserDao(p.GetClient())
 	p.daoAttendance = hr_repository.NewAttendanceDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
 	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoWorkLocation = hr_repository.NewWorkLocationDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShiftProfile = hr_repository.NewShiftProfileDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoRoster = hr_repository.NewRosterDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoHoliday = hr_repository.NewHolidayDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoAnomaly = hr_repository.NewAnomalyDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoLeaveType = hr_repository.NewLeaveTypeDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoLeaveBalance = hr_repository.NewLeaveBalanceDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoLeavePolicy = hr_repository.NewLeavePolicyDao(p.dbRegion.GetClient(), p.businessId)
+
+	// Store for the punch photos, this is optional parameter
+	if _, dataOk := props[hr_common.FLD_BLOB_STORE_TYPE]; dataOk {
+		p.blobStore, err = hr_repository.NewBlobStore(props, p.businessId)
+		if err != nil {
+			return p.errorReturn(err)
+		}
+	}
 
 	// Verify the BusinessId is exist
 	_, err = p.daoPlatformBusiness.Get(p.businessId)
@@ -146,6 +282,12 @@ func (p *attendanceBaseService) List(filter string, sort string, skip int64, lim
 	// Lookup Appuser Info
 	p.lookupAppuser(response)
 
+	// Render the Date-Time in local timezone
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, attendance := range attendances {
+		renderPunchDateTimes(attendance)
+	}
+
 	log.Println("AttendanceService::FindAll - End ")
 	return response, nil
 }
@@ -158,6 +300,9 @@ func (p *attendanceBaseService) Get(appattendance_id string) (utils.Map, error)
 	log.Printf("AttendanceService::FindByCode::  Begin %v", appattendance_id)
 
 	data, err := p.daoAttendance.Get(appattendance_id)
+	if err == nil {
+		renderPunchDateTimes(data)
+	}
 	log.Println("AttendanceService::FindByCode:: End ", err)
 	return data, err
 }
@@ -170,6 +315,9 @@ func (p *attendanceBaseService) Find(filter string) (utils.Map, error) {
 	log.Println("AttendanceService::FindByCode::  Begin ", filter)
 
 	data, err := p.daoAttendance.Find(filter)
+	if err == nil {
+		renderPunchDateTimes(data)
+	}
 	log.Println("AttendanceService::FindByCode:: End ", data, err)
 	return data, err
 }
@@ -258,12 +406,46 @@ func (p *attendanceBaseService) ClockIn(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	// Verify the punch is from the device registered for the staff
+	err = p.validateDevice(p.staffId, indata)
+	if err != nil {
+		return indata, err
+	}
+
+	// Verify the kiosk token shown at the work location and the punch location against the work location,
+	// the coordinates of the kiosk punch are verified when sent
+	workLocId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_WORKLOCATION)
+	isKioskPunch, err := p.validateKioskToken(p.staffId, workLocId, indata)
+	if err != nil {
+		return indata, err
+	}
+	if !isKioskPunch || hasGeoPoint(indata) {
+		err = p.validateGeofence(p.staffId, workLocId, indata)
+		if err != nil {
+			return indata, err
+		}
+	}
+
+	// Reject or Auto-Close the existing open session
+	err = p.handleOpenSession(p.staffId, time.Now(), loc)
+	if err != nil {
+		return indata, err
+	}
+
 	// Create AttendanceId
 	attendanceId := utils.GenerateUniqueId("atten")
 
+	// Keep the photo in the blob store
+	err = p.storePunchPhoto(attendanceId, hr_common.FLD_CLOCK_IN, indata)
+	if err != nil {
+		return indata, err
+	}
+
 	// Add Current DateTime
-	// indata[hr_common.FLD_DATETIME] = time.Now().UTC()
-	indata[hr_common.FLD_DATETIME] = time.Now().In(loc).Format(time.DateTime)
+	setPunchDateTime(indata, time.Now(), loc)
+
+	// Expected shift from the roster of the staff
+	p.assignRosterShift(p.staffId, indata)
 
 	// Create ClockIn Data
 	var clockIn utils.Map = utils.Map{}
@@ -276,6 +458,12 @@ func (p *attendanceBaseService) ClockIn(indata utils.Map) (utils.Map, error) {
 	clockIn[hr_common.FLD_CLOCK_IN] = indata
 
 	_, err = p.daoAttendance.Create(clockIn)
+	if err != nil {
+		return clockIn, err
+	}
+
+	// Mark it as open session for the staff
+	err = p.setOpenSession(p.staffId, attendanceId)
 
 	log.Println("AttendanceService::ClockIn - End")
 	return clockIn, err
@@ -301,11 +489,29 @@ func (p *attendanceBaseService) ClockInMany(indata utils.Map) (utils.Map, error)
 		return indata, err
 	}
 
-	err = p.validateDateTime(indata)
+	// Date-Time is in the sent timezone, otherwise in UTC
+	loc, err := p.getOptionalTimezoneLocation(indata, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	err = normalizePunchDateTime(indata, loc)
 	if err != nil {
 		return nil, err
 	}
 
+	// Reject or Auto-Close the existing open session at the time of this Clock-In
+	clockInTime, err := getPunchDateTime(indata)
+	if err != nil {
+		clockInTime = time.Now()
+	}
+	err = p.handleOpenSession(staffId, clockInTime, loc)
+	if err != nil {
+		return indata, err
+	}
+
+	// Expected shift from the roster of the staff
+	p.assignRosterShift(staffId, indata)
+
 	// Remove StaffId from indata
 	delete(indata, hr_common.FLD_STAFF_ID)
 
@@ -319,6 +525,12 @@ func (p *attendanceBaseService) ClockInMany(indata utils.Map) (utils.Map, error)
 	clockIn[hr_common.FLD_CLOCK_IN] = indata
 
 	insertResult, err := p.daoAttendance.Create(clockIn)
+	if err != nil {
+		return clockIn, err
+	}
+
+	// Mark it as open session for the staff
+	err = p.setOpenSession(staffId, attendanceId)
 
 	log.Println("AttendanceService::ClockInMany - End ", insertResult)
 	return clockIn, err
@@ -338,6 +550,10 @@ func (p *attendanceBaseService) ClockOut(attendance_id string, indata utils.Map)
 	if err != nil {
 		return indata, err
 	}
+	if _, dataOk := data[hr_common.FLD_CLOCK_OUT]; dataOk {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Already Clocked-Out", ErrorDetail: "Attendance is already Clocked-Out"}
+		return indata, err
+	}
 
 	// Get Timezone Location
 	loc, err := p.getTimezoneLocation(indata)
@@ -345,14 +561,61 @@ func (p *attendanceBaseService) ClockOut(attendance_id string, indata utils.Map)
 		return indata, err
 	}
 
+	// Verify the punch is from the device registered for the staff
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	err = p.validateDevice(staffId, indata)
+	if err != nil {
+		return indata, err
+	}
+
+	// Verify the kiosk token or the punch location against the work location used for Clock-In
+	workLocId := ""
+	if clockInData, ok := hr_common.ToMap(data[hr_common.FLD_CLOCK_IN]); ok {
+		workLocId, _ = utils.GetMemberDataStr(clockInData, hr_common.FLD_WORKLOCATION)
+	}
+	kioskWorkLocId, err := utils.GetMemberDataStr(indata, hr_common.FLD_WORKLOCATION)
+	if err != nil {
+		kioskWorkLocId = workLocId
+	}
+	isKioskPunch, err := p.validateKioskToken(staffId, kioskWorkLocId, indata)
+	if err != nil {
+		return indata, err
+	}
+	if !isKioskPunch || hasGeoPoint(indata) {
+		err = p.validateGeofence(staffId, workLocId, indata)
+		if err != nil {
+			return indata, err
+		}
+	}
+
+	// Keep the photo in the blob store
+	err = p.storePunchPhoto(attendance_id, hr_common.FLD_CLOCK_OUT, indata)
+	if err != nil {
+		return indata, err
+	}
+
 	// Update DateTime
-	//indata[hr_common.FLD_DATETIME] = time.Now().UTC()
-	indata[hr_common.FLD_DATETIME] = time.Now().In(loc).Format(time.DateTime)
+	setPunchDateTime(indata, time.Now(), loc)
 
 	// Update Clock-In Interface back
 	data[hr_common.FLD_CLOCK_OUT] = indata
 
+	// Compute Worked time against the shift
+	err = computeAttendanceMetrics(p.daoShift, data)
+	if err != nil {
+		return indata, err
+	}
+
 	_, err = p.daoAttendance.Update(attendance_id, data)
+	if err != nil {
+		return data, err
+	}
+
+	// Earn the comp-off for working on the holiday or weekly off
+	p.earnCompOff(attendance_id, data)
+
+	// Close the open session of the staff
+	err = p.clearOpenSession(staffId, attendance_id)
 
 	log.Println("AttendanceService::ClockIn - End")
 	return data, err
@@ -373,8 +636,18 @@ func (p *attendanceBaseService) ClockOutMany(indata utils.Map) (utils.Map, error
 		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid AttendanceId", ErrorDetail: "No such AttendanceId found"}
 		return nil, err
 	}
+	if _, dataOk := data[hr_common.FLD_CLOCK_OUT]; dataOk {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Already Clocked-Out", ErrorDetail: "Attendance is already Clocked-Out"}
+		return nil, err
+	}
 
-	err = p.validateDateTime(indata)
+	// Date-Time is in the sent timezone, otherwise in the timezone of Clock-In
+	clockInData, _ := hr_common.ToMap(data[hr_common.FLD_CLOCK_IN])
+	loc, err := p.getOptionalTimezoneLocation(indata, hr_common.GetTimezoneLocation(clockInData))
+	if err != nil {
+		return nil, err
+	}
+	err = normalizePunchDateTime(indata, loc)
 	if err != nil {
 		return nil, err
 	}
@@ -385,13 +658,123 @@ func (p *attendanceBaseService) ClockOutMany(indata utils.Map) (utils.Map, error
 	// Update Clock-In Interface back
 	data[hr_common.FLD_CLOCK_OUT] = indata
 
+	// Compute Worked time against the shift
+	err = computeAttendanceMetrics(p.daoShift, data)
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = p.daoAttendance.Update(attendanceId, data)
+	if err != nil {
+		return data, err
+	}
+
+	// Earn the comp-off for working on the holiday or weekly off
+	p.earnCompOff(attendanceId, data)
+
+	// Close the open session of the staff
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	err = p.clearOpenSession(staffId, attendanceId)
 
 	log.Println("AttendanceService::ClockIn - End")
 	return data, err
 
 }
 
+// ***************************************************
+// BreakStart - Start a break within the attendance
+//
+// ***************************************************
+func (p *attendanceBaseService) BreakStart(attendance_id string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("AttendanceService::BreakStart - Begin")
+
+	data, err := p.daoAttendance.Get(attendance_id)
+	if err != nil {
+		return indata, err
+	}
+
+	if _, dataOk := data[hr_common.FLD_CLOCK_OUT]; dataOk {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Already Clocked-Out", ErrorDetail: "Break can not be started after Clock-Out"}
+		return indata, err
+	}
+
+	// Get Timezone Location
+	loc, err := p.getTimezoneLocation(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	breaks, _ := hr_common.ToArray(data[hr_common.FLD_BREAKS])
+	if openBreak := getOpenBreak(breaks); openBreak != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Break Already Started", ErrorDetail: "Previous break is not ended yet"}
+		return indata, err
+	}
+
+	// Paid breaks are not deducted from the worked time
+	isPaid, _ := utils.GetMemberDataBool(indata, hr_common.FLD_IS_PAID_BREAK)
+	delete(indata, hr_common.FLD_IS_PAID_BREAK)
+
+	// Add Current DateTime
+	setPunchDateTime(indata, time.Now(), loc)
+
+	breakData := utils.Map{
+		hr_common.FLD_BREAK_ID:      utils.GenerateUniqueId("brk"),
+		hr_common.FLD_BREAK_START:   indata,
+		hr_common.FLD_IS_PAID_BREAK: isPaid,
+	}
+	breaks = append(breaks, breakData)
+
+	_, err = p.daoAttendance.Update(attendance_id, utils.Map{hr_common.FLD_BREAKS: breaks})
+
+	log.Println("AttendanceService::BreakStart - End")
+	return breakData, err
+}
+
+// ***************************************************
+// BreakEnd - End the open break within the attendance
+//
+// ***************************************************
+func (p *attendanceBaseService) BreakEnd(attendance_id string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("AttendanceService::BreakEnd - Begin")
+
+	data, err := p.daoAttendance.Get(attendance_id)
+	if err != nil {
+		return indata, err
+	}
+
+	// Get Timezone Location
+	loc, err := p.getTimezoneLocation(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	breaks, _ := hr_common.ToArray(data[hr_common.FLD_BREAKS])
+	breakData := getOpenBreak(breaks)
+	if breakData == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Open Break", ErrorDetail: "No break started to end"}
+		return indata, err
+	}
+
+	// Add Current DateTime
+	setPunchDateTime(indata, time.Now(), loc)
+	breakData[hr_common.FLD_BREAK_END] = indata
+
+	// Compute the Break duration
+	breakStartData, _ := hr_common.ToMap(breakData[hr_common.FLD_BREAK_START])
+	breakStartTime, err := getPunchDateTime(breakStartData)
+	if err == nil {
+		breakEndTime, _ := getPunchDateTime(indata)
+		breakData[hr_common.FLD_BREAK_MINUTES] = int(breakEndTime.Sub(breakStartTime).Minutes())
+	}
+
+	_, err = p.daoAttendance.Update(attendance_id, utils.Map{hr_common.FLD_BREAKS: breaks})
+
+	log.Println("AttendanceService::BreakEnd - End")
+	return breakData, err
+}
+
 // ************************
 // Update - Update Service
 //
@@ -411,21 +794,39 @@ func (p *attendanceBaseService) Update(attendance_id string, indata utils.Map) (
 	delete(indata, hr_common.FLD_STAFF_ID)
 	delete(indata, hr_common.FLD_DATETIME)
 
-	clockInData, err := utils.GetMemberData(indata, hr_common.FLD_CLOCK_IN)
-	if err == nil {
-		err = p.validateDateTime(clockInData.(map[string]interface{}))
+	// Date-Time is in the sent timezone, otherwise in the timezone of the existing punch
+	loc, err := p.getOptionalTimezoneLocation(indata, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT} {
+		punchData, ok := hr_common.ToMap(indata[key])
+		if !ok {
+			continue
+		}
+		punchLoc := loc
+		if punchLoc == nil {
+			existingData, _ := hr_common.ToMap(data[key])
+			punchLoc = hr_common.GetTimezoneLocation(existingData)
+		}
+		err = normalizePunchDateTime(punchData, punchLoc)
 		if err != nil {
-			log.Println("Failed to Parse clock_in->date_time", err)
+			log.Println("Failed to Parse "+key+"->date_time", err)
 			return nil, err
 		}
+		indata[key] = punchData
 	}
 
-	clockOutData, err := utils.GetMemberData(indata, hr_common.FLD_CLOCK_OUT)
-	if err == nil {
-		err = p.validateDateTime(clockOutData.(map[string]interface{}))
-		if err != nil {
-			log.Println("Failed to Parse clock_in->date_time", err)
-			return nil, err
+	// Recompute Worked time with the updated values
+	mergedData := utils.MergeMap(data, indata, true)
+	err = computeAttendanceMetrics(p.daoShift, mergedData)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{hr_common.FLD_WORKED_MINUTES, hr_common.FLD_BREAK_MINUTES, hr_common.FLD_PAID_BREAK_MINUTES,
+		hr_common.FLD_LATE_BY_MINUTES, hr_common.FLD_EARLY_EXIT_MINUTES} {
+		if dataVal, dataOk := mergedData[key]; dataOk {
+			indata[key] = dataVal
 		}
 	}
 
@@ -495,71 +896,1911 @@ func (p *attendanceBaseService) DeleteAll(delete_permanent bool) error {
 	return nil
 }
 
-func (p *attendanceBaseService) errorReturn(err error) (AttendanceService, error) {
-	// Close the Database Connection
-	p.EndService()
-	return nil, err
+// **********************************************
+// GetOpenSession - Get the open attendance session
+// (Clock-In without Clock-Out) of the staff
+//
+// **********************************************
+func (p *attendanceBaseService) GetOpenSession(staffId string) (utils.Map, error) {
+
+	log.Println("AttendanceService::GetOpenSession - Begin", staffId)
+
+	data, err := p.getOpenSession(staffId)
+
+	log.Println("AttendanceService::GetOpenSession - End", err)
+	return data, err
 }
 
-func (p *attendanceBaseService) getTimezoneLocation(indata utils.Map) (*time.Location, error) {
-	// Get Timezone Information from
-	businessTimezone, err := utils.GetMemberDataStr(indata, business_common.FLD_BUSINESS_TIMEZONE)
-	if err != nil {
-		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Timezone", ErrorDetail: "No Timezone Information sent"}
-		return nil, err
-	}
-	// Load Location
-	loc, err := time.LoadLocation(businessTimezone)
+// ****************************************************************
+// AutoCloseOpenSessions - Clock-Out the sessions whose shift ended
+// more than the grace period before asOf
+//
+// ****************************************************************
+func (p *attendanceBaseService) AutoCloseOpenSessions(asOf time.Time) (utils.Map, error) {
+
+	log.Println("AttendanceService::AutoCloseOpenSessions - Begin", asOf)
+
+	filter := fmt.Sprintf(`{"%s":{"$exists":true},"%s":{"$exists":false}}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT)
+	response, err := p.daoAttendance.List(filter, "", 0, 0)
 	if err != nil {
-		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
 		return nil, err
 	}
 
-	// Remove Timezone from indata
-	delete(indata, business_common.FLD_BUSINESS_TIMEZONE)
+	closedSessions := []string{}
+	skippedSessions := []utils.Map{}
 
-	return loc, nil
-}
+	openSessions, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, session := range openSessions {
+		attendanceId, _ := utils.GetMemberDataStr(session, hr_common.FLD_ATTENDANCE_ID)
+		skipSession := func(reason string) {
+			skippedSessions = append(skippedSessions, utils.Map{hr_common.FLD_ATTENDANCE_ID: attendanceId, hr_common.FLD_REASON: reason})
+		}
 
-func (p *attendanceBaseService) validateDateTime(indata utils.Map) error {
-	var err error = nil
+		clockInData, _ := hr_common.ToMap(session[hr_common.FLD_CLOCK_IN])
+		clockInTime, err := getPunchDateTime(clockInData)
+		if err != nil {
+			skipSession("Invalid clock_in date_time")
+			continue
+		}
 
-	// Validate given data_time
-	dateTime, err := utils.GetMemberDataStr(indata, hr_common.FLD_DATETIME)
-	if err == nil {
-		_, err = time.Parse(time.DateTime, dateTime)
+		shiftId, err := getPunchShiftId(clockInData)
 		if err != nil {
-			return err
+			skipSession("No shift found for the session")
+			continue
+		}
+		shiftData, err := p.daoShift.Get(shiftId)
+		if err != nil {
+			skipSession("No shift found for the session")
+			continue
+		}
+		_, shiftEnd, err := getShiftWindowForPunch(shiftData, clockInTime)
+		if err != nil {
+			skipSession("Invalid shift timings")
+			continue
 		}
-	}
-	return err
-}
 
-func (p *attendanceBaseService) lookupAppuser(response utils.Map) {
+		if asOf.Sub(shiftEnd) <= p.autoCloseGrace {
+			// Still within the grace period
+			continue
+		}
 
-	// Enumerate All staffs and lookup platform_app_user table
-	dataStaff, err := utils.GetMemberData(response, db_common.LIST_RESULT)
+		// Synthetic Clock-Out at the end of shift for supervisors review
+		clockOutData := utils.Map{hr_common.FLD_AUTO_CLOSED: true}
+		setPunchDateTime(clockOutData, shiftEnd, shiftEnd.Location())
+		closeData := utils.Map{
+			hr_common.FLD_CLOCK_IN:  session[hr_common.FLD_CLOCK_IN],
+			hr_common.FLD_BREAKS:    session[hr_common.FLD_BREAKS],
+			hr_common.FLD_CLOCK_OUT: clockOutData,
+		}
+		if closeData[hr_common.FLD_BREAKS] == nil {
+			delete(closeData, hr_common.FLD_BREAKS)
+		}
+		err = computeAttendanceMetrics(p.daoShift, closeData)
+		if err != nil {
+			skipSession(err.Error())
+			continue
+		}
+		delete(closeData, hr_common.FLD_CLOCK_IN)
+		delete(closeData, hr_common.FLD_BREAKS)
 
-	if err == nil {
-		staffs := dataStaff.([]utils.Map)
-		for _, staff := range staffs {
-			p.mergeUserInfo(staff)
-			//log.Println(staff)
+		_, err = p.daoAttendance.Update(attendanceId, closeData)
+		if err != nil {
+			skipSession(err.Error())
+			continue
 		}
+
+		staffId, _ := utils.GetMemberDataStr(session, hr_common.FLD_STAFF_ID)
+		err = p.clearOpenSession(staffId, attendanceId)
+		if err != nil {
+			skipSession(err.Error())
+			continue
+		}
+
+		closedSessions = append(closedSessions, attendanceId)
 	}
+
+	log.Println("AttendanceService::AutoCloseOpenSessions - End", len(closedSessions))
+	return utils.Map{
+		hr_common.FLD_CLOSED_SESSIONS:  closedSessions,
+		hr_common.FLD_SKIPPED_SESSIONS: skippedSessions,
+	}, nil
 }
 
-func (p *attendanceBaseService) mergeUserInfo(staffInfo utils.Map) {
+// ****************************************************************
+// GetDailyStatus - Get the status of the staff for each day between
+// from and to (YYYY-MM-DD), in the order of precedence
+// present/half_day, on_leave, holiday, weekly_off and absent
+//
+// ****************************************************************
+func (p *attendanceBaseService) GetDailyStatus(staffId string, from string, to string) (utils.Map, error) {
 
-	staffId, _ := utils.GetMemberDataStr(staffInfo, hr_common.FLD_STAFF_ID)
-	staffData, err := p.daoPlatformAppUser.Get(staffId)
-	if err == nil {
-		// Delete unwanted fields
-		delete(staffData, db_common.FLD_CREATED_AT)
-		delete(staffData, db_common.FLD_UPDATED_AT)
-		delete(staffData, platform_common.FLD_APP_USER_ID)
+	log.Println("AttendanceService::GetDailyStatus - Begin", staffId, from, to)
 
-		// Make it as Array for backward compatible, since all MongoDB Lookups data returned as array
-		staffInfo[hr_common.FLD_STAFF_INFO] = []utils.Map{staffData}
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid from", ErrorDetail: "from date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid to", ErrorDetail: "to date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	if toDate.Before(fromDate) || toDate.Sub(fromDate).Hours()/24 >= MAX_DAILY_STATUS_DAYS {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Date Range", ErrorDetail: fmt.Sprintf("to date should be after from date and within %v days", MAX_DAILY_STATUS_DAYS)}
+		return nil, err
+	}
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return nil, err
+	}
+
+	// Worked minutes & attendances of each day
+	workedMinutes := map[string]int{}
+	attendanceIds := map[string][]string{}
+	openDays := map[string]bool{}
+
+	daoAttendance := hr_repository.NewAttendanceDao(p.dbRegion.GetClient(), p.businessId, staffId)
+	// Dates are in the timezone of each punch, hence a day added on both ends of the range
+	filter := fmt.Sprintf(`{"%s.%s":{"$gte":%s,"$lt":%s}}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME,
+		hr_common.ToDateFilter(fromDate.AddDate(0, 0, -1)), hr_common.ToDateFilter(toDate.AddDate(0, 0, 2)))
+	response, err := daoAttendance.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, attendance := range attendances {
+		clockInData, _ := hr_common.ToMap(attendance[hr_common.FLD_CLOCK_IN])
+		clockInTime, err := getPunchDateTime(clockInData)
+		if err != nil {
+			continue
+		}
+		day := clockInTime.Format(time.DateOnly)
+		if day < from || day > to {
+			continue
+		}
+
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+		attendanceIds[day] = append(attendanceIds[day], attendanceId)
+
+		if _, dataOk := attendance[hr_common.FLD_CLOCK_OUT]; !dataOk {
+			// Session still open, consider the staff present
+			openDays[day] = true
+			continue
+		}
+		minutes, err := utils.GetMemberDataInt(attendance, hr_common.FLD_WORKED_MINUTES, true)
+		if err != nil {
+			// Records clocked-out before the worked minutes were computed
+			_ = computeAttendanceMetrics(p.daoShift, attendance)
+			minutes, _ = utils.GetMemberDataInt(attendance, hr_common.FLD_WORKED_MINUTES, true)
+		}
+		workedMinutes[day] += minutes
+	}
+
+	leaveDays, err := getApprovedLeaveDays(hr_repository.NewLeaveDao(p.dbRegion.GetClient(), p.businessId, staffId), from, to)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := getHolidayDates(p.daoHoliday, from, to)
+	if err != nil {
+		return nil, err
+	}
+	weeklyOffs := getStaffWeeklyOffs(p.daoShiftProfile, staffData)
+	rosterDays, err := getRosterDays(p.daoRoster, staffId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	dailyStatus := []utils.Map{}
+	statusSummary := utils.Map{}
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		day := date.Format(time.DateOnly)
+		dayStatus := utils.Map{hr_common.FLD_DATE: day}
+
+		status := ""
+		if ids, dataOk := attendanceIds[day]; dataOk {
+			dayStatus[hr_common.FLD_ATTENDANCE_IDS] = ids
+			dayStatus[hr_common.FLD_WORKED_MINUTES] = workedMinutes[day]
+			if openDays[day] || workedMinutes[day] >= p.fullDayMinutes {
+				status = hr_common.ATTENDANCE_STATUS_PRESENT
+			} else if workedMinutes[day] >= p.halfDayMinutes {
+				status = hr_common.ATTENDANCE_STATUS_HALF_DAY
+			}
+		}
+		if len(status) == 0 {
+			if leaveId, dataOk := leaveDays[day]; dataOk {
+				status = hr_common.ATTENDANCE_STATUS_ON_LEAVE
+				dayStatus[hr_common.FLD_LEAVE_ID] = leaveId
+			} else if holidayId, dataOk := holidays[day]; dataOk {
+				status = hr_common.ATTENDANCE_STATUS_HOLIDAY
+				dayStatus[hr_common.FLD_HOLIDAY_ID] = holidayId
+			} else if isRosterOffDay(rosterDays, weeklyOffs, date) {
+				status = hr_common.ATTENDANCE_STATUS_WEEKLY_OFF
+			} else {
+				status = hr_common.ATTENDANCE_STATUS_ABSENT
+			}
+		}
+		dayStatus[hr_common.FLD_ATTENDANCE_STATUS] = status
+
+		count, _ := statusSummary[status].(int)
+		statusSummary[status] = count + 1
+		dailyStatus = append(dailyStatus, dayStatus)
+	}
+
+	log.Println("AttendanceService::GetDailyStatus - End", len(dailyStatus))
+	return utils.Map{
+		hr_common.FLD_STAFF_ID:       staffId,
+		hr_common.FLD_DAILY_STATUS:   dailyStatus,
+		hr_common.FLD_STATUS_SUMMARY: statusSummary,
+	}, nil
+}
+
+// ****************************************************************
+// MigrateDateTimes - Convert the legacy local date_time strings of
+// the punches, which are in the given timezone, to UTC dates
+//
+// ****************************************************************
+func (p *attendanceBaseService) MigrateDateTimes(timezone string) (utils.Map, error) {
+
+	log.Println("AttendanceService::MigrateDateTimes - Begin", timezone)
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`{"$or":[{"%s.%s":{"$type":"string"}},{"%s.%s":{"$type":"string"}}]}`,
+		hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME, hr_common.FLD_CLOCK_OUT, hr_common.FLD_DATETIME)
+	response, err := p.daoAttendance.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedCount := 0
+	failedRecords := []utils.Map{}
+
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, attendance := range attendances {
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+
+		punches := []any{attendance[hr_common.FLD_CLOCK_IN], attendance[hr_common.FLD_CLOCK_OUT]}
+		breaks, breaksOk := hr_common.ToArray(attendance[hr_common.FLD_BREAKS])
+		for _, item := range breaks {
+			if breakData, ok := hr_common.ToMap(item); ok {
+				punches = append(punches, breakData[hr_common.FLD_BREAK_START], breakData[hr_common.FLD_BREAK_END])
+			}
+		}
+
+		err = nil
+		for _, punch := range punches {
+			punchData, ok := hr_common.ToMap(punch)
+			if !ok {
+				continue
+			}
+			if _, isStr := punchData[hr_common.FLD_DATETIME].(string); isStr {
+				err = normalizePunchDateTime(punchData, loc)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			failedRecords = append(failedRecords, utils.Map{hr_common.FLD_ATTENDANCE_ID: attendanceId, hr_common.FLD_REASON: err.Error()})
+			continue
+		}
+
+		updateData := utils.Map{}
+		for _, key := range []string{hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT} {
+			if dataVal, dataOk := attendance[key]; dataOk {
+				updateData[key] = dataVal
+			}
+		}
+		if breaksOk {
+			updateData[hr_common.FLD_BREAKS] = breaks
+		}
+
+		_, err = p.daoAttendance.Update(attendanceId, updateData)
+		if err != nil {
+			failedRecords = append(failedRecords, utils.Map{hr_common.FLD_ATTENDANCE_ID: attendanceId, hr_common.FLD_REASON: err.Error()})
+			continue
+		}
+		migratedCount++
+	}
+
+	log.Println("AttendanceService::MigrateDateTimes - End", migratedCount, len(failedRecords))
+	return utils.Map{
+		hr_common.FLD_MIGRATED_COUNT: migratedCount,
+		hr_common.FLD_FAILED_RECORDS: failedRecords,
+	}, nil
+}
+
+// ****************************************************************
+// ImportPunchLog - Import the punch log exported from the biometric
+// devices (csv or dat), the punches are mapped to the staff using
+// device_user_id, deduplicated and paired into Clock-In/Clock-Out
+// sessions using the staff's shift. Returns the result of each row
+//
+// ****************************************************************
+func (p *attendanceBaseService) ImportPunchLog(indata utils.Map) (utils.Map, error) {
+
+	log.Println("AttendanceService::ImportPunchLog - Begin")
+
+	// Device punches are in the business timezone
+	loc, err := p.getTimezoneLocation(indata)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := utils.GetMemberDataStr(indata, hr_common.FLD_PUNCH_LOG)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No punch_log", ErrorDetail: "Punch log content should be sent in punch_log"}
+		return nil, err
+	}
+	format, _ := utils.GetMemberDataStr(indata, hr_common.FLD_PUNCH_LOG_FORMAT)
+	entries, err := hr_common.ParsePunchLog(format, content, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	dedupeMinutes, err := utils.GetMemberDataInt(indata, hr_common.FLD_DEDUPE_MINUTES, true)
+	if err != nil || dedupeMinutes < 0 {
+		dedupeMinutes = DEFAULT_PUNCH_DEDUPE_MINUTES
+	}
+	dedupeWindow := time.Duration(dedupeMinutes) * time.Minute
+	defaultDeviceId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_DEVICE_ID)
+
+	importResults := []utils.Map{}
+	staffEntries := map[string][]int{}
+	deviceStaffIds := map[string]string{}
+	for index, entry := range entries {
+		if len(entry.DeviceId) == 0 {
+			entries[index].DeviceId = defaultDeviceId
+		}
+
+		result := utils.Map{
+			hr_common.FLD_ROW:            entry.Row,
+			hr_common.FLD_DEVICE_USER_ID: entry.DeviceUserId,
+		}
+		importResults = append(importResults, result)
+		if len(entry.Error) > 0 {
+			setImportError(result, entry.Error)
+			continue
+		}
+		result[hr_common.FLD_DATETIME] = entry.DateTime.Format(time.DateTime)
+
+		staffId, dataOk := deviceStaffIds[entry.DeviceUserId]
+		if !dataOk {
+			staffId = p.getStaffIdByDeviceUserId(entry.DeviceUserId)
+			deviceStaffIds[entry.DeviceUserId] = staffId
+		}
+		if len(staffId) == 0 {
+			setImportError(result, "No staff found for the device_user_id")
+			continue
+		}
+		result[hr_common.FLD_STAFF_ID] = staffId
+		staffEntries[staffId] = append(staffEntries[staffId], index)
+	}
+
+	for staffId, indexes := range staffEntries {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return entries[indexes[i]].DateTime.Before(entries[indexes[j]].DateTime)
+		})
+
+		punches := []hr_common.PunchLogEntry{}
+		results := []utils.Map{}
+		for _, index := range indexes {
+			punches = append(punches, entries[index])
+			results = append(results, importResults[index])
+		}
+		p.importStaffPunches(staffId, punches, results, loc, dedupeWindow)
+	}
+
+	statusSummary := utils.Map{}
+	for _, result := range importResults {
+		status, _ := utils.GetMemberDataStr(result, hr_common.FLD_IMPORT_STATUS)
+		count, _ := statusSummary[status].(int)
+		statusSummary[status] = count + 1
+	}
+
+	log.Println("AttendanceService::ImportPunchLog - End", len(importResults))
+	return utils.Map{
+		hr_common.FLD_IMPORT_RESULTS: importResults,
+		hr_common.FLD_STATUS_SUMMARY: statusSummary,
+	}, nil
+}
+
+// ****************************************************************
+// GetPhoto - Get the photo attached with the Clock-In/Clock-Out,
+// punchType is clock_in or clock_out
+//
+// ****************************************************************
+func (p *attendanceBaseService) GetPhoto(attendance_id string, punchType string) (utils.Map, error) {
+
+	log.Println("AttendanceService::GetPhoto - Begin", attendance_id, punchType)
+
+	if punchType != hr_common.FLD_CLOCK_IN && punchType != hr_common.FLD_CLOCK_OUT {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Punch Type", ErrorDetail: "Punch type should be clock_in or clock_out"}
+		return nil, err
+	}
+
+	data, err := p.daoAttendance.Get(attendance_id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the photo stored for the punch of this attendance is returned
+	punchData, _ := hr_common.ToMap(data[punchType])
+	photoRef, err := utils.GetMemberDataStr(punchData, hr_common.FLD_PHOTO_REF)
+	if err != nil || photoRef != getPunchPhotoRef(attendance_id, punchType) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Photo", ErrorDetail: "No photo attached with the " + punchType}
+		return nil, err
+	}
+
+	if p.blobStore == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Photo Storage", ErrorDetail: "Blob store is not configured for the service"}
+		return nil, err
+	}
+	photo, err := p.blobStore.Get(photoRef)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _ := utils.GetMemberDataStr(punchData, hr_common.FLD_PHOTO_CONTENT_TYPE)
+
+	log.Println("AttendanceService::GetPhoto - End", len(photo))
+	return utils.Map{
+		hr_common.FLD_ATTENDANCE_ID:      attendance_id,
+		hr_common.FLD_PHOTO_REF:          photoRef,
+		hr_common.FLD_PHOTO_CONTENT_TYPE: contentType,
+		hr_common.FLD_PHOTO:              base64.StdEncoding.EncodeToString(photo),
+	}, nil
+}
+
+// ****************************************************************
+// DetectAnomalies - Scan the attendances clocked-in between from and
+// to (YYYY-MM-DD in UTC) for impossible travel, device shared by the
+// staff, punches at odd hours and long sessions. New findings are
+// kept in the anomalies collection for review
+//
+// ****************************************************************
+func (p *attendanceBaseService) DetectAnomalies(from string, to string) (utils.Map, error) {
+
+	log.Println("AttendanceService::DetectAnomalies - Begin", from, to)
+
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid from", ErrorDetail: "from date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil || toDate.Before(fromDate) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid to", ErrorDetail: "to date should be in YYYY-MM-DD format and after from date"}
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`{"%s.%s":{"$gte":%s,"$lt":%s}}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME,
+		hr_common.ToDateFilter(fromDate), hr_common.ToDateFilter(toDate.AddDate(0, 0, 1)))
+	sortBy := fmt.Sprintf(`{"%s.%s":1}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME)
+	response, err := p.daoAttendance.List(filter, sortBy, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+
+	shiftResponse, err := p.daoShift.List("", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	shifts, _ := shiftResponse[db_common.LIST_RESULT].([]utils.Map)
+
+	findings := detectImpossibleTravel(attendances, p.maxTravelSpeed)
+	findings = append(findings, detectSharedDevices(attendances)...)
+	findings = append(findings, detectOddHours(attendances, shifts, p.oddHoursMargin)...)
+	findings = append(findings, detectLongSessions(attendances, p.longSession)...)
+
+	newAnomalies := []utils.Map{}
+	statusSummary := utils.Map{}
+	for _, finding := range findings {
+		// Skip the findings reported already in the previous runs
+		keyFilter, _ := json.Marshal(utils.Map{hr_common.FLD_ANOMALY_KEY: finding[hr_common.FLD_ANOMALY_KEY]})
+		_, err := p.daoAnomaly.Find(string(keyFilter))
+		if err == nil {
+			continue
+		}
+
+		finding[hr_common.FLD_ANOMALY_ID] = utils.GenerateUniqueId("anom")
+		finding[hr_common.FLD_BUSINESS_ID] = p.businessId
+		finding[hr_common.FLD_ANOMALY_STATUS] = hr_common.ANOMALY_STATUS_OPEN
+		finding[hr_common.FLD_DETECTED_AT] = time.Now()
+		_, err = p.daoAnomaly.Create(finding)
+		if err != nil {
+			return nil, err
+		}
+
+		anomalyType, _ := utils.GetMemberDataStr(finding, hr_common.FLD_ANOMALY_TYPE)
+		count, _ := statusSummary[anomalyType].(int)
+		statusSummary[anomalyType] = count + 1
+		newAnomalies = append(newAnomalies, finding)
+	}
+
+	log.Println("AttendanceService::DetectAnomalies - End", len(newAnomalies))
+	return utils.Map{
+		hr_common.FLD_ANOMALIES:      newAnomalies,
+		hr_common.FLD_STATUS_SUMMARY: statusSummary,
+	}, nil
+}
+
+// ****************************************************************
+// ListAnomalies - List the anomalies detected
+//
+// ****************************************************************
+func (p *attendanceBaseService) ListAnomalies(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("AttendanceService::ListAnomalies - Begin")
+
+	response, err := p.daoAnomaly.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("AttendanceService::ListAnomalies - End")
+	return response, nil
+}
+
+// ****************************************************************
+// ReviewAnomaly - Confirm or dismiss the anomaly, anomaly_status,
+// acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *attendanceBaseService) ReviewAnomaly(anomalyId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("AttendanceService::ReviewAnomaly - Begin", anomalyId)
+
+	_, err := p.daoAnomaly.Get(anomalyId)
+	if err != nil {
+		return nil, err
+	}
+
+	status, _ := utils.GetMemberDataStr(indata, hr_common.FLD_ANOMALY_STATUS)
+	if status != hr_common.ANOMALY_STATUS_CONFIRMED && status != hr_common.ANOMALY_STATUS_DISMISSED {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid anomaly_status", ErrorDetail: "anomaly_status should be confirmed or dismissed"}
+		return nil, err
+	}
+	actedBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No acted_by", ErrorDetail: "Reviewer id should be sent in acted_by"}
+		return nil, err
+	}
+
+	reviewData := utils.Map{
+		hr_common.FLD_ANOMALY_STATUS: status,
+		hr_common.FLD_ACTED_BY:       actedBy,
+		hr_common.FLD_ACTED_AT:       time.Now(),
+	}
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		reviewData[hr_common.FLD_REMARKS] = remarks
+	}
+
+	data, err := p.daoAnomaly.Update(anomalyId, reviewData)
+
+	log.Println("AttendanceService::ReviewAnomaly - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// CreditCompOffs - Credit the comp-offs for the closed attendances
+// from and to (YYYY-MM-DD) on the holidays & weekly offs, to cover
+// the sessions closed by the import, auto close or regularization.
+// Safe to run again
+//
+// ****************************************************************
+func (p *attendanceBaseService) CreditCompOffs(from string, to string) (utils.Map, error) {
+
+	log.Println("AttendanceService::CreditCompOffs - Begin", from, to)
+
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid from", ErrorDetail: "from date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil || toDate.Before(fromDate) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid to", ErrorDetail: "to date should be in YYYY-MM-DD format and after from date"}
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`{"%s.%s":{"$gte":%s,"$lt":%s},"%s":{"$exists":true}}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME,
+		hr_common.ToDateFilter(fromDate), hr_common.ToDateFilter(toDate.AddDate(0, 0, 1)), hr_common.FLD_CLOCK_OUT)
+	response, err := p.daoAttendance.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	postedCount := 0
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, attendance := range attendances {
+		_, hadCompOff := attendance[hr_common.FLD_COMP_OFF_DAYS]
+		posted, err := p.creditCompOff(attendance)
+		if err != nil {
+			return nil, err
+		}
+
+		// Also fill comp_off_days of the attendance whose credit was posted without it
+		compOffDays, dataOk := attendance[hr_common.FLD_COMP_OFF_DAYS]
+		if dataOk && (posted || !hadCompOff) {
+			attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+			_, err = p.daoAttendance.Update(attendanceId, utils.Map{hr_common.FLD_COMP_OFF_DAYS: compOffDays})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if posted {
+			postedCount++
+		}
+	}
+
+	log.Println("AttendanceService::CreditCompOffs - End", postedCount)
+	return utils.Map{
+		hr_common.FLD_POSTED_COUNT: postedCount,
+	}, nil
+}
+
+func (p *attendanceBaseService) errorReturn(err error) (AttendanceService, error) {
+	// Close the Database Connection
+	p.EndService()
+	return nil, err
+}
+
+func (p *attendanceBaseService) getTimezoneLocation(indata utils.Map) (*time.Location, error) {
+	// Get Timezone Information from
+	businessTimezone, err := utils.GetMemberDataStr(indata, business_common.FLD_BUSINESS_TIMEZONE)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Timezone", ErrorDetail: "No Timezone Information sent"}
+		return nil, err
+	}
+	// Load Location
+	loc, err := time.LoadLocation(businessTimezone)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
+		return nil, err
+	}
+
+	// Remove Timezone from indata
+	delete(indata, business_common.FLD_BUSINESS_TIMEZONE)
+
+	return loc, nil
+}
+
+// getOpenSession - Get the attendance referred by the staff's last_clock_in_attendance_id
+// when it is not clocked-out yet
+func (p *attendanceBaseService) getOpenSession(staffId string) (utils.Map, error) {
+
+	errNoSession := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Open Session", ErrorDetail: "Staff has no open attendance session"}
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return nil, err
+	}
+
+	attendanceId, err := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_LAST_CLOCK_IN)
+	if err != nil {
+		return nil, errNoSession
+	}
+
+	data, err := p.daoAttendance.Get(attendanceId)
+	if err != nil {
+		return nil, errNoSession
+	}
+
+	// Already Clocked-Out
+	if _, dataOk := data[hr_common.FLD_CLOCK_OUT]; dataOk {
+		return nil, errNoSession
+	}
+
+	return data, nil
+}
+
+// handleOpenSession - Reject the Clock-In or Auto-Close the open session based on the policy. The session is
+// closed at the end of its shift, or at the closeAt time (the new Clock-In) when that is earlier. The session
+// clocked-in after the closeAt time is not closed and the Clock-In is rejected
+func (p *attendanceBaseService) handleOpenSession(staffId string, closeAt time.Time, loc *time.Location) error {
+
+	openSession, err := p.getOpenSession(staffId)
+	if err != nil {
+		// No open session
+		return nil
+	}
+
+	attendanceId, _ := utils.GetMemberDataStr(openSession, hr_common.FLD_ATTENDANCE_ID)
+
+	clockInData, _ := hr_common.ToMap(openSession[hr_common.FLD_CLOCK_IN])
+	clockInTime, err := getPunchDateTime(clockInData)
+	if p.openSessionPolicy != hr_common.OPEN_SESSION_POLICY_AUTO_CLOSE || (err == nil && closeAt.Before(clockInTime)) {
+		err := &utils.AppError{
+			ErrorCode:   "S30102",
+			ErrorMsg:    "Already Clocked-In",
+			ErrorDetail: "Staff has an open attendance session " + attendanceId}
+		return err
+	}
+
+	// Auto-Close the open session
+	if sessionEnd := p.getSessionEnd(clockInData, clockInTime); err == nil && sessionEnd.Before(closeAt) {
+		closeAt = sessionEnd
+	}
+	clockOutData := utils.Map{hr_common.FLD_AUTO_CLOSED: true}
+	setPunchDateTime(clockOutData, closeAt, loc)
+	openSession[hr_common.FLD_CLOCK_OUT] = clockOutData
+	err = computeAttendanceMetrics(p.daoShift, openSession)
+	if err != nil {
+		return err
+	}
+	_, err = p.daoAttendance.Update(attendanceId, openSession)
+	if err != nil {
+		return err
+	}
+
+	return p.clearOpenSession(staffId, attendanceId)
+}
+
+// getSessionEnd - End of the shift of the session, otherwise DEFAULT_MAX_SESSION_HOURS after the Clock-In
+func (p *attendanceBaseService) getSessionEnd(clockInData utils.Map, clockInTime time.Time) time.Time {
+
+	sessionEnd := clockInTime.Add(DEFAULT_MAX_SESSION_HOURS * time.Hour)
+	shiftId, err := getPunchShiftId(clockInData)
+	if err != nil {
+		return sessionEnd
+	}
+	shiftData, err := p.daoShift.Get(shiftId)
+	if err != nil {
+		return sessionEnd
+	}
+	_, shiftEnd, err := getShiftWindowForPunch(shiftData, clockInTime)
+	if err != nil || !shiftEnd.After(clockInTime) {
+		return sessionEnd
+	}
+	return shiftEnd
+}
+
+// setOpenSession - Keep the attendanceId as the open session of the staff
+func (p *attendanceBaseService) setOpenSession(staffId string, attendanceId string) error {
+
+	_, err := p.daoStaff.Update(staffId, utils.Map{hr_common.FLD_STAFF_LAST_CLOCK_IN: attendanceId})
+	return err
+}
+
+// clearOpenSession - Clear the open session of the staff if it refers the attendanceId
+func (p *attendanceBaseService) clearOpenSession(staffId string, attendanceId string) error {
+
+	return clearStaffOpenSession(p.daoStaff, staffId, attendanceId)
+}
+
+// hasGeoPoint - Whether the punch is sent with the coordinates
+func hasGeoPoint(punchData utils.Map) bool {
+
+	_, hasLatitude := punchData[hr_common.FLD_LATITUDE]
+	_, hasLongitude := punchData[hr_common.FLD_LONGITUDE]
+	return hasLatitude || hasLongitude
+}
+
+// validateGeofence - Verify the punch coordinates fall within the staff's work location.
+// The punch is either rejected or flagged as off-site based on the work location's geofence_action
+func (p *attendanceBaseService) validateGeofence(staffId string, workLocId string, punchData utils.Map) error {
+
+	// Staff's assigned work location takes precedence over the one sent in the punch
+	staffData, err := p.daoStaff.Get(staffId)
+	if err == nil {
+		assignedWorkLocId, err := utils.GetMemberDataStr(staffData, hr_common.FLD_WORKLOCATION_ID)
+		if err == nil {
+			workLocId = assignedWorkLocId
+		}
+	}
+
+	if utils.IsEmpty(workLocId) {
+		// No work location to verify
+		return nil
+	}
+
+	workLocData, err := p.daoWorkLocation.Get(workLocId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid work_location", ErrorDetail: "Given work_location is not exist"}
+		return err
+	}
+
+	radius, errRadius := hr_common.GetMemberDataFloat(workLocData, hr_common.FLD_GEOFENCE_RADIUS)
+	polygonVal, polygonOk := workLocData[hr_common.FLD_GEOFENCE_POLYGON]
+	if errRadius != nil && !polygonOk {
+		// No geofence configured for this work location
+		return nil
+	}
+
+	isInside := false
+	distance := -1.0
+	punchPoint, err := hr_common.GetGeoPoint(punchData)
+	if err == nil {
+		if polygonOk {
+			polygon, err := hr_common.GetGeoPolygon(polygonVal)
+			if err == nil {
+				isInside = hr_common.IsPointInPolygon(punchPoint, polygon)
+			}
+		}
+		if !isInside && errRadius == nil {
+			center, err := hr_common.GetGeoPoint(workLocData)
+			if err == nil {
+				distance = hr_common.GetDistanceInMeters(center, punchPoint)
+				isInside = distance <= radius
+			}
+		}
+	}
+
+	if isInside {
+		punchData[hr_common.FLD_IS_OFF_SITE] = false
+		return nil
+	}
+
+	// Punch without coordinates or outside the geofence
+	action, _ := utils.GetMemberDataStr(workLocData, hr_common.FLD_GEOFENCE_ACTION)
+	if action == hr_common.GEOFENCE_ACTION_FLAG {
+		punchData[hr_common.FLD_IS_OFF_SITE] = true
+		if distance >= 0 {
+			punchData[hr_common.FLD_OFF_SITE_DIST] = math.Round(distance)
+		}
+		return nil
+	}
+
+	err = &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Outside Work Location", ErrorDetail: "Punch location is outside the assigned work location"}
+	return err
+}
+
+// getPunchPhotoRef - Reference of the photo of the punch in the blob store
+func getPunchPhotoRef(attendanceId string, punchType string) string {
+	return "attendance/" + attendanceId + "/" + punchType
+}
+
+// storePunchPhoto - Keep the base64 photo of the punch in the blob store and replace it with the photo_ref,
+// photo_ref sent by the client is not trusted and removed
+func (p *attendanceBaseService) storePunchPhoto(attendanceId string, punchType string, punchData utils.Map) error {
+
+	delete(punchData, hr_common.FLD_PHOTO_REF)
+	delete(punchData, hr_common.FLD_PHOTO_CONTENT_TYPE)
+
+	photo, err := utils.GetMemberDataStr(punchData, hr_common.FLD_PHOTO)
+	delete(punchData, hr_common.FLD_PHOTO)
+	if err != nil {
+		// No photo sent
+		return nil
+	}
+
+	if p.blobStore == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Photo Storage", ErrorDetail: "Blob store is not configured for the service"}
+		return err
+	}
+
+	errPhoto := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Photo", ErrorDetail: "Photo should be a base64 encoded image"}
+
+	photoData, contentType, ok := hr_common.DecodeBase64Data(photo)
+	if !ok {
+		return errPhoto
+	}
+	if len(photoData) > p.maxPhotoSize {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Photo Too Large", ErrorDetail: fmt.Sprintf("Photo size should be within %v KB", p.maxPhotoSize/1024)}
+		return err
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return errPhoto
+	}
+
+	photoRef := getPunchPhotoRef(attendanceId, punchType)
+	err = p.blobStore.Put(photoRef, photoData)
+	if err != nil {
+		return err
+	}
+
+	punchData[hr_common.FLD_PHOTO_REF] = photoRef
+	punchData[hr_common.FLD_PHOTO_CONTENT_TYPE] = contentType
+	return nil
+}
+
+// assignRosterShift - Keep the shift_id & roster_id of the roster the punch belongs to in the Clock-In,
+// the shift is not rostered for the off day. The shift sent by the client is not trusted
+func (p *attendanceBaseService) assignRosterShift(staffId string, punchData utils.Map) {
+
+	delete(punchData, hr_common.FLD_SHIFT_ID)
+	delete(punchData, hr_common.FLD_ROSTER_ID)
+
+	punchTime, err := getPunchDateTime(punchData)
+	if err != nil {
+		return
+	}
+	rosterData := getRosterEntryForPunch(p.daoRoster, p.daoShift, staffId, punchTime)
+	if rosterData == nil {
+		return
+	}
+	punchData[hr_common.FLD_ROSTER_ID] = rosterData[hr_common.FLD_ROSTER_ID]
+	punchData[hr_common.FLD_SHIFT_ID], _ = utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID)
+}
+
+// getMaxSession - Session longer than its shift (rostered or type_of_work) and the auto clock-out grace is
+// a missed Clock-Out, DEFAULT_MAX_SESSION_HOURS when the session has no shift
+func (p *attendanceBaseService) getMaxSession(clockInData utils.Map, clockInTime time.Time) time.Duration {
+
+	maxSession := DEFAULT_MAX_SESSION_HOURS * time.Hour
+	shiftId, err := getPunchShiftId(clockInData)
+	if err != nil || len(shiftId) == 0 {
+		return maxSession
+	}
+	shiftData, err := p.daoShift.Get(shiftId)
+	if err != nil {
+		return maxSession
+	}
+	shiftStart, shiftEnd, err := getShiftWindow(shiftData, clockInTime)
+	if err != nil {
+		return maxSession
+	}
+	return shiftEnd.Sub(shiftStart) + p.autoCloseGrace
+}
+
+// getStaffIdByDeviceUserId - Get the staff mapped to the user id of the biometric device
+func (p *attendanceBaseService) getStaffIdByDeviceUserId(deviceUserId string) string {
+
+	filter, _ := json.Marshal(utils.Map{hr_common.FLD_DEVICE_USER_ID: deviceUserId})
+	staffData, err := p.daoStaff.Find(string(filter))
+	if err != nil {
+		return ""
+	}
+
+	staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+	return staffId
+}
+
+// importStaffPunches - Pair the punches (sorted by time) of the staff into sessions, the punch within
+// the session length after the open Clock-In is its Clock-Out, otherwise it starts a new session
+func (p *attendanceBaseService) importStaffPunches(staffId string, punches []hr_common.PunchLogEntry, results []utils.Map,
+	loc *time.Location, dedupeWindow time.Duration) {
+
+	staffData, _ := p.daoStaff.Get(staffId)
+	shiftId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_TYPE_OF_WORK)
+
+	// Punches already recorded around the imported punches
+	maxSession := DEFAULT_MAX_SESSION_HOURS * time.Hour
+	existingPunches := p.getStaffPunchTimes(staffId, punches[0].DateTime.Add(-maxSession), punches[len(punches)-1].DateTime.Add(maxSession))
+	openSession, _ := p.getOpenSession(staffId)
+
+	for index, punch := range punches {
+		result := results[index]
+
+		if isDuplicatePunch(punch.DateTime, existingPunches, dedupeWindow) {
+			result[hr_common.FLD_IMPORT_STATUS] = hr_common.IMPORT_STATUS_DUPLICATE
+			continue
+		}
+
+		punchData := utils.Map{hr_common.FLD_PUNCH_SOURCE: hr_common.PUNCH_SOURCE_DEVICE_IMPORT}
+		setPunchDateTime(punchData, punch.DateTime, loc)
+		if len(punch.DeviceId) > 0 {
+			punchData[hr_common.FLD_DEVICE_ID] = punch.DeviceId
+		}
+
+		if openSession != nil {
+			clockInData, _ := hr_common.ToMap(openSession[hr_common.FLD_CLOCK_IN])
+			clockInTime, err := getPunchDateTime(clockInData)
+			if err == nil && punch.DateTime.After(clockInTime) && punch.DateTime.Sub(clockInTime) <= p.getMaxSession(clockInData, clockInTime) {
+				// Clock-Out of the open session
+				attendanceId, _ := utils.GetMemberDataStr(openSession, hr_common.FLD_ATTENDANCE_ID)
+				openSession[hr_common.FLD_CLOCK_OUT] = punchData
+				err = computeAttendanceMetrics(p.daoShift, openSession)
+				if err == nil {
+					_, err = p.daoAttendance.Update(attendanceId, openSession)
+				}
+				if err == nil {
+					err = p.clearOpenSession(staffId, attendanceId)
+				}
+				if err != nil {
+					delete(openSession, hr_common.FLD_CLOCK_OUT)
+					setImportError(result, err.Error())
+					continue
+				}
+
+				result[hr_common.FLD_IMPORT_STATUS] = hr_common.IMPORT_STATUS_CLOCK_OUT
+				result[hr_common.FLD_ATTENDANCE_ID] = attendanceId
+				existingPunches = append(existingPunches, punch.DateTime)
+				openSession = nil
+				continue
+			}
+		}
+
+		// New session, the previous open session if any is left for the auto clock-out
+		if len(shiftId) > 0 {
+			punchData[hr_common.FLD_TYPE_OF_WORK] = shiftId
+		}
+		p.assignRosterShift(staffId, punchData)
+		attendanceId := utils.GenerateUniqueId("atten")
+		clockIn := utils.Map{
+			hr_common.FLD_ATTENDANCE_ID: attendanceId,
+			hr_common.FLD_BUSINESS_ID:   p.businessId,
+			hr_common.FLD_STAFF_ID:      staffId,
+			hr_common.FLD_CLOCK_IN:      punchData,
+		}
+		_, err := p.daoAttendance.Create(clockIn)
+		if err == nil {
+			err = p.setOpenSession(staffId, attendanceId)
+		}
+		if err != nil {
+			setImportError(result, err.Error())
+			continue
+		}
+
+		result[hr_common.FLD_IMPORT_STATUS] = hr_common.IMPORT_STATUS_CLOCK_IN
+		result[hr_common.FLD_ATTENDANCE_ID] = attendanceId
+		existingPunches = append(existingPunches, punch.DateTime)
+		openSession = clockIn
+	}
+}
+
+// getStaffPunchTimes - Get the Clock-In/Clock-Out times of the staff's attendances between from and to
+func (p *attendanceBaseService) getStaffPunchTimes(staffId string, from time.Time, to time.Time) []time.Time {
+
+	punchTimes := []time.Time{}
+
+	daoAttendance := hr_repository.NewAttendanceDao(p.dbRegion.GetClient(), p.businessId, staffId)
+	filter := fmt.Sprintf(`{"%s.%s":{"$gte":%s,"$lte":%s}}`, hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME,
+		hr_common.ToDateFilter(from), hr_common.ToDateFilter(to))
+	response, err := daoAttendance.List(filter, "", 0, 0)
+	if err != nil {
+		return punchTimes
+	}
+
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, attendance := range attendances {
+		for _, key := range []string{hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT} {
+			punchData, ok := hr_common.ToMap(attendance[key])
+			if !ok {
+				continue
+			}
+			if punchTime, err := getPunchDateTime(punchData); err == nil {
+				punchTimes = append(punchTimes, punchTime)
+			}
+		}
+	}
+
+	return punchTimes
+}
+
+// getOptionalTimezoneLocation - Get Timezone Location when sent in indata, otherwise the default location
+func (p *attendanceBaseService) getOptionalTimezoneLocation(indata utils.Map, defaultLoc *time.Location) (*time.Location, error) {
+
+	if _, dataOk := indata[business_common.FLD_BUSINESS_TIMEZONE]; !dataOk {
+		return defaultLoc, nil
+	}
+	return p.getTimezoneLocation(indata)
+}
+
+func (p *attendanceBaseService) lookupAppuser(response utils.Map) {
+
+	// Enumerate All staffs and lookup platform_app_user table
+	dataStaff, err := utils.GetMemberData(response, db_common.LIST_RESULT)
+
+	if err == nil {
+		staffs := dataStaff.([]utils.Map)
+		for _, staff := range staffs {
+			p.mergeUserInfo(staff)
+			//log.Println(staff)
+		}
+	}
+}
+
+func (p *attendanceBaseService) mergeUserInfo(staffInfo utils.Map) {
+
+	staffId, _ := utils.GetMemberDataStr(staffInfo, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoPlatformAppUser.Get(staffId)
+	if err == nil {
+		// Delete unwanted fields
+		delete(staffData, db_common.FLD_CREATED_AT)
+		delete(staffData, db_common.FLD_UPDATED_AT)
+		delete(staffData, platform_common.FLD_APP_USER_ID)
+
+		// Make it as Array for backward compatible, since all MongoDB Lookups data returned as array
+		staffInfo[hr_common.FLD_STAFF_INFO] = []utils.Map{staffData}
+	}
+}
+
+// getPunchDateTime - Get the date_time of Clock-In/Clock-Out data in the timezone of the punch
+func getPunchDateTime(punchData utils.Map) (time.Time, error) {
+
+	dateTime, ok := hr_common.ToDateTime(punchData[hr_common.FLD_DATETIME], hr_common.GetTimezoneLocation(punchData))
+	if !ok {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid date_time", ErrorDetail: "date_time value is invalid"}
+		return time.Time{}, err
+	}
+
+	return dateTime, nil
+}
+
+// setPunchDateTime - Keep the date_time of the punch in UTC along with its timezone
+func setPunchDateTime(punchData utils.Map, dateTime time.Time, loc *time.Location) {
+	punchData[hr_common.FLD_DATETIME] = dateTime.UTC()
+	punchData[hr_common.FLD_TIMEZONE] = loc.String()
+}
+
+// normalizePunchDateTime - Convert the local date_time string of the punch to UTC, the string is
+// in the timezone sent along with the punch, otherwise in the given location
+func normalizePunchDateTime(punchData utils.Map, loc *time.Location) error {
+
+	dataVal, dataOk := punchData[hr_common.FLD_DATETIME]
+	if !dataOk {
+		return nil
+	}
+	if _, dataOk := punchData[hr_common.FLD_TIMEZONE]; dataOk {
+		loc = hr_common.GetTimezoneLocation(punchData)
+	}
+
+	dateTime, ok := hr_common.ToDateTime(dataVal, loc)
+	if !ok {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid date_time", ErrorDetail: "date_time value is invalid"}
+		return err
+	}
+	setPunchDateTime(punchData, dateTime, loc)
+	return nil
+}
+
+// renderPunchDateTimes - Add the local_date_time to the punches of the attendance
+func renderPunchDateTimes(data utils.Map) {
+
+	punches := []any{data[hr_common.FLD_CLOCK_IN], data[hr_common.FLD_CLOCK_OUT]}
+	breaks, _ := hr_common.ToArray(data[hr_common.FLD_BREAKS])
+	for _, item := range breaks {
+		if breakData, ok := hr_common.ToMap(item); ok {
+			punches = append(punches, breakData[hr_common.FLD_BREAK_START], breakData[hr_common.FLD_BREAK_END])
+		}
+	}
+
+	for _, punch := range punches {
+		punchData, ok := hr_common.ToMap(punch)
+		if !ok {
+			continue
+		}
+		localDateTime, ok := hr_common.ToLocalDateTimeStr(punchData[hr_common.FLD_DATETIME], hr_common.GetTimezoneLocation(punchData))
+		if ok {
+			punchData[hr_common.FLD_LOCAL_DATETIME] = localDateTime
+		}
+	}
+}
+
+// getShiftWindow - Get the start & end time of the shift which starts on the given date
+func getShiftWindow(shiftData utils.Map, onDate time.Time) (time.Time, time.Time, error) {
+
+	shiftFrom, err := utils.GetMemberDataStr(shiftData, hr_common.FLD_SHIFT_FROM)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	fromTime, err := time.Parse(time.TimeOnly, shiftFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	shiftTo, err := utils.GetMemberDataStr(shiftData, hr_common.FLD_SHIFT_TO)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	toTime, err := time.Parse(time.TimeOnly, shiftTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	shiftStart := time.Date(onDate.Year(), onDate.Month(), onDate.Day(),
+		fromTime.Hour(), fromTime.Minute(), fromTime.Second(), 0, onDate.Location())
+	shiftEnd := time.Date(onDate.Year(), onDate.Month(), onDate.Day(),
+		toTime.Hour(), toTime.Minute(), toTime.Second(), 0, onDate.Location())
+
+	// Shift ends on the next day
+	isRollover, _ := utils.GetMemberDataBool(shiftData, hr_common.FLD_IS_SHIFT_ROLLOVER_NEXT_DAY)
+	if isRollover || !shiftEnd.After(shiftStart) {
+		shiftEnd = shiftEnd.AddDate(0, 0, 1)
+	}
+
+	return shiftStart, shiftEnd, nil
+}
+
+// getShiftWindowForPunch - Get the shift window nearest to the Clock-In time, for the
+// rollover shifts the Clock-In after midnight belongs to the shift started on previous day
+func getShiftWindowForPunch(shiftData utils.Map, clockInTime time.Time) (time.Time, time.Time, error) {
+
+	shiftStart, shiftEnd, err := getShiftWindow(shiftData, clockInTime)
+	if err != nil {
+		return shiftStart, shiftEnd, err
+	}
+
+	prevStart, prevEnd, _ := getShiftWindow(shiftData, clockInTime.AddDate(0, 0, -1))
+	if prevEnd.Sub(prevStart) > 0 && prevEnd.After(clockInTime) &&
+		absDuration(clockInTime.Sub(prevStart)) < absDuration(clockInTime.Sub(shiftStart)) {
+		return prevStart, prevEnd, nil
+	}
+
+	return shiftStart, shiftEnd, nil
+}
+
+// getPunchShiftId - Shift of the Clock-In, the rostered shift takes precedence over the type_of_work
+func getPunchShiftId(clockInData utils.Map) (string, error) {
+
+	if _, dataOk := clockInData[hr_common.FLD_ROSTER_ID]; dataOk {
+		shiftId, _ := utils.GetMemberDataStr(clockInData, hr_common.FLD_SHIFT_ID)
+		if len(shiftId) == 0 {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Shift", ErrorDetail: "Staff is off on the day as per the roster"}
+			return "", err
+		}
+		return shiftId, nil
+	}
+	return utils.GetMemberDataStr(clockInData, hr_common.FLD_TYPE_OF_WORK)
+}
+
+// computeAttendanceMetrics - Compute worked, break, late & early-exit minutes of the attendance
+// against the rostered shift, otherwise the shift referred in clock_in.type_of_work
+func computeAttendanceMetrics(daoShift hr_repository.ShiftDao, data utils.Map) error {
+
+	clockInData, ok := hr_common.ToMap(data[hr_common.FLD_CLOCK_IN])
+	if !ok {
+		return nil
+	}
+	clockOutData, ok := hr_common.ToMap(data[hr_common.FLD_CLOCK_OUT])
+	if !ok {
+		return nil
+	}
+
+	clockInTime, err := getPunchDateTime(clockInData)
+	if err != nil {
+		return err
+	}
+	clockOutTime, err := getPunchDateTime(clockOutData)
+	if err != nil {
+		return err
+	}
+
+	if clockOutTime.Before(clockInTime) {
+		err := &utils.AppError{
+			ErrorCode:   "S30102",
+			ErrorMsg:    "Invalid clock_out",
+			ErrorDetail: "clock_out date_time should be after clock_in date_time"}
+		return err
+	}
+
+	breakMinutes := 0
+	paidBreakMinutes := 0
+	lateByMinutes := 0
+	earlyExitMinutes := 0
+
+	shiftId, err := getPunchShiftId(clockInData)
+	if err == nil {
+		shiftData, err := daoShift.Get(shiftId)
+		if err == nil {
+			shiftStart, shiftEnd, err := getShiftWindowForPunch(shiftData, clockInTime)
+			if err == nil {
+				if clockInTime.After(shiftStart) {
+					lateByMinutes = int(clockInTime.Sub(shiftStart).Minutes())
+				}
+				if clockOutTime.Before(shiftEnd) {
+					earlyExitMinutes = int(shiftEnd.Sub(clockOutTime).Minutes())
+				}
+			}
+			breakMinutes, _ = utils.GetMemberDataInt(shiftData, hr_common.FLD_SHIFT_BREAK_MINUTES, true)
+		}
+	}
+
+	// Breaks punched within the session take precedence over the shift's break allowance
+	breaks, ok := hr_common.ToArray(data[hr_common.FLD_BREAKS])
+	if ok && len(breaks) > 0 {
+		breakMinutes, paidBreakMinutes = getBreakMinutes(breaks, clockInTime, clockOutTime)
+	}
+
+	totalMinutes := int(clockOutTime.Sub(clockInTime).Minutes())
+	if breakMinutes > totalMinutes {
+		breakMinutes = totalMinutes
+	}
+
+	data[hr_common.FLD_WORKED_MINUTES] = totalMinutes - breakMinutes
+	data[hr_common.FLD_BREAK_MINUTES] = breakMinutes
+	data[hr_common.FLD_PAID_BREAK_MINUTES] = paidBreakMinutes
+	data[hr_common.FLD_LATE_BY_MINUTES] = lateByMinutes
+	data[hr_common.FLD_EARLY_EXIT_MINUTES] = earlyExitMinutes
+
+	return nil
+}
+
+// getOpenBreak - Get the break which is not ended yet
+func getOpenBreak(breaks []any) utils.Map {
+	for _, item := range breaks {
+		breakData, ok := hr_common.ToMap(item)
+		if !ok {
+			continue
+		}
+		if _, dataOk := breakData[hr_common.FLD_BREAK_END]; !dataOk {
+			return breakData
+		}
+	}
+	return nil
+}
+
+// getBreakMinutes - Get the unpaid & paid break minutes within the session,
+// break which is not ended is considered till the Clock-Out
+func getBreakMinutes(breaks []any, clockInTime time.Time, clockOutTime time.Time) (int, int) {
+
+	unpaidMinutes := 0
+	paidMinutes := 0
+	for _, item := range breaks {
+		breakData, ok := hr_common.ToMap(item)
+		if !ok {
+			continue
+		}
+
+		breakStartData, _ := hr_common.ToMap(breakData[hr_common.FLD_BREAK_START])
+		breakStartTime, err := getPunchDateTime(breakStartData)
+		if err != nil {
+			continue
+		}
+
+		breakEndTime := clockOutTime
+		if breakEndData, ok := hr_common.ToMap(breakData[hr_common.FLD_BREAK_END]); ok {
+			if endTime, err := getPunchDateTime(breakEndData); err == nil && endTime.Before(clockOutTime) {
+				breakEndTime = endTime
+			}
+		}
+		if breakStartTime.Before(clockInTime) {
+			breakStartTime = clockInTime
+		}
+		if !breakEndTime.After(breakStartTime) {
+			continue
+		}
+
+		minutes := int(breakEndTime.Sub(breakStartTime).Minutes())
+		isPaid, _ := utils.GetMemberDataBool(breakData, hr_common.FLD_IS_PAID_BREAK)
+		if isPaid {
+			paidMinutes += minutes
+		} else {
+			unpaidMinutes += minutes
+		}
+	}
+
+	return unpaidMinutes, paidMinutes
+}
+
+// getApprovedLeaveDays - Get the dates (YYYY-MM-DD) between from and to covered by the approved leaves
+func getApprovedLeaveDays(daoLeave hr_repository.LeaveDao, from string, to string) (map[string]string, error) {
+
+	fromDate, _ := time.Parse(time.DateOnly, from)
+	toDate, _ := time.Parse(time.DateOnly, to)
+
+	// Dates are in the timezone of each leave, hence a day added on both ends of the range
+	filter := fmt.Sprintf(`{"%s":true,"%s":{"$lt":%s},"%s":{"$gte":%s}}`,
+		hr_common.FLD_LEAVE_APPROVED, hr_common.FLD_LEAVE_FROM, hr_common.ToDateFilter(toDate.AddDate(0, 0, 2)),
+		hr_common.FLD_LEAVE_TO, hr_common.ToDateFilter(fromDate.AddDate(0, 0, -1)))
+	response, err := daoLeave.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	leaveDays := map[string]string{}
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leave := range leaves {
+		leaveId, _ := utils.GetMemberDataStr(leave, hr_common.FLD_LEAVE_ID)
+
+		loc := hr_common.GetTimezoneLocation(leave)
+		leaveFrom, fromOk := hr_common.ToDateTime(leave[hr_common.FLD_LEAVE_FROM], loc)
+		leaveTo, toOk := hr_common.ToDateTime(leave[hr_common.FLD_LEAVE_TO], loc)
+		if !fromOk || !toOk {
+			continue
+		}
+		leaveFromDate := time.Date(leaveFrom.Year(), leaveFrom.Month(), leaveFrom.Day(), 0, 0, 0, 0, time.UTC)
+		leaveToDate := time.Date(leaveTo.Year(), leaveTo.Month(), leaveTo.Day(), 0, 0, 0, 0, time.UTC)
+		for date := leaveFromDate; !date.After(leaveToDate); date = date.AddDate(0, 0, 1) {
+			leaveDays[date.Format(time.DateOnly)] = leaveId
+		}
+	}
+
+	return leaveDays, nil
+}
+
+// getHolidayDates - Get the holidays between from and to as date (YYYY-MM-DD) to holiday_id
+func getHolidayDates(daoHoliday hr_repository.HolidayDao, from string, to string) (map[string]string, error) {
+
+	fromDate, _ := time.Parse(time.DateOnly, from)
+	toDate, _ := time.Parse(time.DateOnly, to)
+
+	// Dates are in the timezone of each holiday, hence a day added on both ends of the range
+	filter := fmt.Sprintf(`{"%s":{"$gte":%s,"$lt":%s}}`, hr_common.FLD_HOLIDAY_DATE,
+		hr_common.ToDateFilter(fromDate.AddDate(0, 0, -1)), hr_common.ToDateFilter(toDate.AddDate(0, 0, 2)))
+	response, err := daoHoliday.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays := map[string]string{}
+	holidayList, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, holiday := range holidayList {
+		holidayDate, ok := hr_common.ToDateTime(holiday[hr_common.FLD_HOLIDAY_DATE], hr_common.GetTimezoneLocation(holiday))
+		if ok {
+			holidays[holidayDate.Format(time.DateOnly)], _ = utils.GetMemberDataStr(holiday, hr_common.FLD_HOLIDAY_ID)
+		}
+	}
+
+	return holidays, nil
+}
+
+// getStaffWeeklyOffs - Get the weekly offs from the shift profile of the staff
+func getStaffWeeklyOffs(daoShiftProfile hr_repository.ShiftProfileDao, staffData utils.Map) map[time.Weekday]bool {
+
+	weeklyOffs := map[time.Weekday]bool{}
+
+	shiftProfileId, err := utils.GetMemberDataStr(staffData, hr_common.FLD_SHIFT_PROFILE_ID)
+	if err != nil {
+		return weeklyOffs
+	}
+	shiftProfileData, err := daoShiftProfile.Get(shiftProfileId)
+	if err != nil {
+		return weeklyOffs
+	}
+
+	days, _ := hr_common.ToArray(shiftProfileData[hr_common.FLD_WEEKLY_OFFS])
+	for _, day := range days {
+		if weekday, ok := hr_common.ParseWeekday(day); ok {
+			weeklyOffs[weekday] = true
+		}
+	}
+
+	return weeklyOffs
+}
+
+// clearStaffOpenSession - Clear the staff's last_clock_in_attendance_id if it refers the attendanceId
+func clearStaffOpenSession(daoStaff hr_repository.StaffDao, staffId string, attendanceId string) error {
+
+	staffData, err := daoStaff.Get(staffId)
+	if err != nil {
+		// Staff not exist anymore, nothing to clear
+		return nil
+	}
+
+	lastAttendanceId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_LAST_CLOCK_IN)
+	if lastAttendanceId != attendanceId {
+		return nil
+	}
+
+	_, err = daoStaff.Update(staffId, utils.Map{hr_common.FLD_STAFF_LAST_CLOCK_IN: ""})
+	return err
+}
+
+// validateKioskToken - Verify the kiosk_token in the punch is the current token of the work location, the
+// token proves the presence at the work location. The token is accepted once per staff, its time step is
+// kept in the punch to detect the reuse. Returns false when the punch is not from the kiosk
+func (p *attendanceBaseService) validateKioskToken(staffId string, workLocId string, punchData utils.Map) (bool, error) {
+
+	token, err := utils.GetMemberDataStr(punchData, hr_common.FLD_KIOSK_TOKEN)
+	hasToken := err == nil && len(token) > 0
+	if utils.IsEmpty(workLocId) {
+		if hasToken {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No work_location", ErrorDetail: "work_location should be sent along with the kiosk_token"}
+			return false, err
+		}
+		return false, nil
+	}
+
+	workLocData, err := p.daoWorkLocation.Get(workLocId)
+	if err != nil {
+		if hasToken {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid work_location", ErrorDetail: "Given work_location is not exist"}
+			return false, err
+		}
+		return false, nil
+	}
+
+	if !hasToken {
+		kioskRequired, _ := utils.GetMemberDataBool(workLocData, hr_common.FLD_KIOSK_REQUIRED)
+		if kioskRequired {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No kiosk_token", ErrorDetail: "Punch at the work location is allowed only with the kiosk token"}
+			return false, err
+		}
+		return false, nil
+	}
+
+	secret, _ := utils.GetMemberDataStr(workLocData, hr_common.FLD_KIOSK_SECRET)
+	if len(secret) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Kiosk not enabled", ErrorDetail: "Kiosk is not enabled for the work location"}
+		return false, err
+	}
+
+	interval := getKioskTokenInterval(workLocData)
+	tokenStep, ok := hr_common.MatchTotpStep(secret, token, time.Now(), interval, KIOSK_TOKEN_SKEW)
+	if !ok {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid kiosk_token", ErrorDetail: "kiosk_token is expired or not of the work location"}
+		return false, err
+	}
+
+	// Reject the token used already by the staff for another punch
+	filter, _ := json.Marshal(utils.Map{
+		hr_common.FLD_STAFF_ID: staffId,
+		"$or": []utils.Map{
+			{hr_common.FLD_CLOCK_IN + "." + hr_common.FLD_WORKLOCATION: workLocId, hr_common.FLD_CLOCK_IN + "." + hr_common.FLD_KIOSK_TOKEN_STEP: tokenStep},
+			{hr_common.FLD_CLOCK_OUT + "." + hr_common.FLD_WORKLOCATION: workLocId, hr_common.FLD_CLOCK_OUT + "." + hr_common.FLD_KIOSK_TOKEN_STEP: tokenStep},
+		},
+	})
+	response, err := p.daoAttendance.List(string(filter), "", 0, 1)
+	if err != nil {
+		return false, err
+	}
+	if used, _ := response[db_common.LIST_RESULT].([]utils.Map); len(used) > 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Used kiosk_token", ErrorDetail: "kiosk_token is already used for a punch, scan the current token at the kiosk"}
+		return false, err
+	}
+
+	// Token is not useful once verified
+	delete(punchData, hr_common.FLD_KIOSK_TOKEN)
+	punchData[hr_common.FLD_KIOSK_TOKEN_STEP] = tokenStep
+	punchData[hr_common.FLD_WORKLOCATION] = workLocId
+	punchData[hr_common.FLD_PUNCH_SOURCE] = hr_common.PUNCH_SOURCE_KIOSK
+	return true, nil
+}
+
+// validateDevice - Verify the device_fingerprint in the punch is registered for the staff. Staff
+// without registered devices are allowed unless the device binding is required for the business
+func (p *attendanceBaseService) validateDevice(staffId string, punchData utils.Map) error {
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return err
+	}
+
+	devices := getRegisteredDevices(staffData)
+	if len(devices) == 0 && !p.requireDevice {
+		return nil
+	}
+
+	fingerprint, _ := utils.GetMemberDataStr(punchData, hr_common.FLD_DEVICE_FINGERPRINT)
+	if len(fingerprint) == 0 || findRegisteredDevice(devices, fingerprint) < 0 {
+		err := &utils.AppError{ErrorCode: hr_common.ERROR_CODE_UNREGISTERED_DEVICE, ErrorMsg: "Unregistered Device", ErrorDetail: "Punch is not from the device registered for the staff"}
+		return err
+	}
+	return nil
+}
+
+// earnCompOff - Credit the comp-off for the closed attendance, best effort so that the Clock-Out is not blocked.
+// The failed credit is left to the CreditCompOffs sweep
+func (p *attendanceBaseService) earnCompOff(attendanceId string, data utils.Map) {
+
+	_, err := p.creditCompOff(data)
+	if err != nil {
+		log.Println("AttendanceService::earnCompOff - Failed to credit the comp-off", attendanceId, err)
+		return
+	}
+	compOffDays, dataOk := data[hr_common.FLD_COMP_OFF_DAYS]
+	if !dataOk {
+		return
+	}
+	_, err = p.daoAttendance.Update(attendanceId, utils.Map{hr_common.FLD_COMP_OFF_DAYS: compOffDays})
+	if err != nil {
+		log.Println("AttendanceService::earnCompOff - Failed to update comp_off_days", attendanceId, err)
+	}
+}
+
+// creditCompOff - Credit the comp-off to the staff for the attendance on the holiday or weekly off, a full
+// day for the full-day worked minutes and half day for the half-day. The credit is linked to the attendance
+// and valid for comp_off_validity_days of the comp-off leave type, nothing is credited without such type.
+// comp_off_days is set on the attendance whether the credit is posted now or was posted already
+func (p *attendanceBaseService) creditCompOff(data utils.Map) (bool, error) {
+
+	clockOutData, dataOk := hr_common.ToMap(data[hr_common.FLD_CLOCK_OUT])
+	if !dataOk {
+		return false, nil
+	}
+	// Auto-Closed session earns nothing until its punches are regularized
+	autoClosed, _ := utils.GetMemberDataBool(clockOutData, hr_common.FLD_AUTO_CLOSED)
+	regularized, _ := utils.GetMemberDataBool(data, hr_common.FLD_IS_REGULARIZED)
+	if autoClosed && !regularized {
+		return false, nil
+	}
+	filter, _ := json.Marshal(utils.Map{hr_common.FLD_IS_COMP_OFF: true})
+	leaveType, err := p.daoLeaveType.Find(string(filter))
+	if err != nil {
+		return false, nil
+	}
+
+	workedMinutes, _ := utils.GetMemberDataInt(data, hr_common.FLD_WORKED_MINUTES, true)
+	compOffDays := 0.0
+	if workedMinutes >= p.fullDayMinutes {
+		compOffDays = 1
+	} else if workedMinutes >= p.halfDayMinutes {
+		compOffDays = 0.5
+	} else {
+		return false, nil
+	}
+
+	// Work date in the timezone of the Clock-In
+	clockInData, _ := hr_common.ToMap(data[hr_common.FLD_CLOCK_IN])
+	clockInTime, err := getPunchDateTime(clockInData)
+	if err != nil {
+		return false, err
+	}
+	workDate := clockInTime.Format(time.DateOnly)
+
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return false, err
+	}
+	holidays, err := getHolidayDates(p.daoHoliday, workDate, workDate)
+	if err != nil {
+		return false, err
+	}
+	rosterDays, err := getRosterDays(p.daoRoster, staffId, workDate, workDate)
+	if err != nil {
+		return false, err
+	}
+	if _, isHoliday := holidays[workDate]; !isHoliday && !isRosterOffDay(rosterDays, getStaffWeeklyOffs(p.daoShiftProfile, staffData), clockInTime) {
+		return false, nil
+	}
+
+	// Validity as per the policy applicable to the staff
+	policies, err := getLeavePolicies(p.daoLeavePolicy)
+	if err != nil {
+		return false, err
+	}
+	leaveType = resolveLeaveType(policies, staffData, leaveType, workDate)
+
+	validityDays, err := utils.GetMemberDataInt(leaveType, hr_common.FLD_COMP_OFF_VALIDITY_DAYS, true)
+	if err != nil || validityDays <= 0 {
+		validityDays = DEFAULT_COMP_OFF_VALIDITY_DAYS
+	}
+	expiresOn := clockInTime.AddDate(0, 0, validityDays).Format(time.DateOnly)
+
+	attendanceId, _ := utils.GetMemberDataStr(data, hr_common.FLD_ATTENDANCE_ID)
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+	entryKey := fmt.Sprintf("%s:%s", hr_common.BALANCE_ENTRY_COMP_OFF, attendanceId)
+	posted, err := postLedgerEntry(p.daoLeaveBalance, p.businessId, staffId, leaveTypeId, clockInTime.Year(),
+		hr_common.BALANCE_ENTRY_COMP_OFF, compOffDays, entryKey, utils.Map{
+			hr_common.FLD_ATTENDANCE_ID: attendanceId,
+			hr_common.FLD_WORK_DATE:     workDate,
+			hr_common.FLD_EXPIRES_ON:    expiresOn,
+		})
+	if err != nil {
+		return false, err
+	}
+	data[hr_common.FLD_COMP_OFF_DAYS] = compOffDays
+	return posted, nil
+}
+
+// newAnomaly - Prepare the anomaly finding of the staff
+func newAnomaly(staffId string, anomalyType string, anomalyKey string, attendanceIds []string, details utils.Map) utils.Map {
+	return utils.Map{
+		hr_common.FLD_STAFF_ID:        staffId,
+		hr_common.FLD_ANOMALY_TYPE:    anomalyType,
+		hr_common.FLD_ANOMALY_KEY:     anomalyType + ":" + anomalyKey,
+		hr_common.FLD_ATTENDANCE_IDS:  attendanceIds,
+		hr_common.FLD_ANOMALY_DETAILS: details,
+	}
+}
+
+// detectImpossibleTravel - Find the consecutive punches of the staff which are too far apart for the time between them
+func detectImpossibleTravel(attendances []utils.Map, maxSpeedKmph float64) []utils.Map {
+
+	type punchEvent struct {
+		attendanceId string
+		punchType    string
+		dateTime     time.Time
+		point        hr_common.GeoPoint
+	}
+
+	staffEvents := map[string][]punchEvent{}
+	for _, attendance := range attendances {
+		staffId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_STAFF_ID)
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+		for _, punchType := range []string{hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT} {
+			punchData, ok := hr_common.ToMap(attendance[punchType])
+			if !ok {
+				continue
+			}
+			dateTime, err := getPunchDateTime(punchData)
+			if err != nil {
+				continue
+			}
+			point, err := hr_common.GetGeoPoint(punchData)
+			if err != nil {
+				continue
+			}
+			staffEvents[staffId] = append(staffEvents[staffId], punchEvent{attendanceId, punchType, dateTime, point})
+		}
+	}
+
+	findings := []utils.Map{}
+	for staffId, events := range staffEvents {
+		sort.SliceStable(events, func(i, j int) bool { return events[i].dateTime.Before(events[j].dateTime) })
+
+		for index := 1; index < len(events); index++ {
+			prev := events[index-1]
+			curr := events[index]
+
+			distance := hr_common.GetDistanceInMeters(prev.point, curr.point)
+			if distance < MIN_TRAVEL_DISTANCE {
+				continue
+			}
+			hours := curr.dateTime.Sub(prev.dateTime).Hours()
+			details := utils.Map{
+				hr_common.FLD_DISTANCE:   math.Round(distance),
+				hr_common.FLD_FROM_PUNCH: utils.Map{hr_common.FLD_ATTENDANCE_ID: prev.attendanceId, hr_common.FLD_PUNCH_TYPE: prev.punchType, hr_common.FLD_DATETIME: prev.dateTime.UTC()},
+				hr_common.FLD_TO_PUNCH:   utils.Map{hr_common.FLD_ATTENDANCE_ID: curr.attendanceId, hr_common.FLD_PUNCH_TYPE: curr.punchType, hr_common.FLD_DATETIME: curr.dateTime.UTC()},
+			}
+			if hours > 0 {
+				speed := distance / 1000 / hours
+				if speed <= maxSpeedKmph {
+					continue
+				}
+				details[hr_common.FLD_SPEED_KMPH] = math.Round(speed)
+			}
+
+			anomalyKey := prev.attendanceId + ":" + prev.punchType + ":" + curr.attendanceId + ":" + curr.punchType
+			attendanceIds := []string{prev.attendanceId}
+			if curr.attendanceId != prev.attendanceId {
+				attendanceIds = append(attendanceIds, curr.attendanceId)
+			}
+			findings = append(findings, newAnomaly(staffId, hr_common.ANOMALY_TYPE_IMPOSSIBLE_TRAVEL, anomalyKey, attendanceIds, details))
+		}
+	}
+
+	return findings
+}
+
+// detectSharedDevices - Find the devices used to punch for more than one staff, the device is identified
+// by the device_fingerprint of the punch, otherwise by the device_id
+func detectSharedDevices(attendances []utils.Map) []utils.Map {
+
+	deviceStaffs := map[string]map[string][]string{}
+	deviceFields := map[string]string{}
+	for _, attendance := range attendances {
+		staffId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_STAFF_ID)
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+		for _, punchType := range []string{hr_common.FLD_CLOCK_IN, hr_common.FLD_CLOCK_OUT} {
+			punchData, _ := hr_common.ToMap(attendance[punchType])
+			deviceField := hr_common.FLD_DEVICE_FINGERPRINT
+			deviceId, err := utils.GetMemberDataStr(punchData, deviceField)
+			if err != nil || len(deviceId) == 0 {
+				deviceField = hr_common.FLD_DEVICE_ID
+				deviceId, err = utils.GetMemberDataStr(punchData, deviceField)
+			}
+			if err != nil || len(deviceId) == 0 {
+				continue
+			}
+			source, _ := utils.GetMemberDataStr(punchData, hr_common.FLD_PUNCH_SOURCE)
+			if source == hr_common.PUNCH_SOURCE_DEVICE_IMPORT {
+				// Biometric devices are shared by design
+				continue
+			}
+			if deviceStaffs[deviceId] == nil {
+				deviceStaffs[deviceId] = map[string][]string{}
+				deviceFields[deviceId] = deviceField
+			}
+			staffAttendances := deviceStaffs[deviceId][staffId]
+			if len(staffAttendances) == 0 || staffAttendances[len(staffAttendances)-1] != attendanceId {
+				deviceStaffs[deviceId][staffId] = append(staffAttendances, attendanceId)
+			}
+		}
+	}
+
+	findings := []utils.Map{}
+	for deviceId, staffs := range deviceStaffs {
+		if len(staffs) < 2 {
+			continue
+		}
+		staffIds := []string{}
+		for staffId := range staffs {
+			staffIds = append(staffIds, staffId)
+		}
+		sort.Strings(staffIds)
+
+		for _, staffId := range staffIds {
+			otherStaffIds := []string{}
+			for _, otherStaffId := range staffIds {
+				if otherStaffId != staffId {
+					otherStaffIds = append(otherStaffIds, otherStaffId)
+				}
+			}
+			details := utils.Map{
+				deviceFields[deviceId]:        deviceId,
+				hr_common.FLD_OTHER_STAFF_IDS: otherStaffIds,
+			}
+			anomalyKey := deviceId + ":" + staffId + ":" + strings.Join(otherStaffIds, ",")
+			findings = append(findings, newAnomaly(staffId, hr_common.ANOMALY_TYPE_SHARED_DEVICE, anomalyKey, staffs[staffId], details))
+		}
+	}
+
+	return findings
+}
+
+// detectOddHours - Find the Clock-Ins which are not within any shift window of the business
+func detectOddHours(attendances []utils.Map, shifts []utils.Map, margin time.Duration) []utils.Map {
+
+	findings := []utils.Map{}
+	if len(shifts) == 0 {
+		// No shifts to verify
+		return findings
+	}
+
+	for _, attendance := range attendances {
+		clockInData, _ := hr_common.ToMap(attendance[hr_common.FLD_CLOCK_IN])
+		clockInTime, err := getPunchDateTime(clockInData)
+		if err != nil {
+			continue
+		}
+
+		withinShift := false
+		for _, shiftData := range shifts {
+			shiftStart, shiftEnd, err := getShiftWindowForPunch(shiftData, clockInTime)
+			if err == nil && !clockInTime.Before(shiftStart.Add(-margin)) && !clockInTime.After(shiftEnd.Add(margin)) {
+				withinShift = true
+				break
+			}
+		}
+		if withinShift {
+			continue
+		}
+
+		staffId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_STAFF_ID)
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+		details := utils.Map{hr_common.FLD_LOCAL_DATETIME: clockInTime.Format(time.DateTime)}
+		findings = append(findings, newAnomaly(staffId, hr_common.ANOMALY_TYPE_ODD_HOURS, attendanceId, []string{attendanceId}, details))
+	}
+
+	return findings
+}
+
+// detectLongSessions - Find the sessions longer than the given duration, open sessions are measured till now
+func detectLongSessions(attendances []utils.Map, longSession time.Duration) []utils.Map {
+
+	findings := []utils.Map{}
+	for _, attendance := range attendances {
+		clockInData, _ := hr_common.ToMap(attendance[hr_common.FLD_CLOCK_IN])
+		clockInTime, err := getPunchDateTime(clockInData)
+		if err != nil {
+			continue
+		}
+
+		clockOutTime := time.Now()
+		if clockOutData, ok := hr_common.ToMap(attendance[hr_common.FLD_CLOCK_OUT]); ok {
+			clockOutTime, err = getPunchDateTime(clockOutData)
+			if err != nil {
+				continue
+			}
+		}
+
+		sessionLength := clockOutTime.Sub(clockInTime)
+		if sessionLength <= longSession {
+			continue
+		}
+
+		staffId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_STAFF_ID)
+		attendanceId, _ := utils.GetMemberDataStr(attendance, hr_common.FLD_ATTENDANCE_ID)
+		details := utils.Map{hr_common.FLD_SESSION_MINUTES: int(sessionLength.Minutes())}
+		findings = append(findings, newAnomaly(staffId, hr_common.ANOMALY_TYPE_LONG_SESSION, attendanceId, []string{attendanceId}, details))
+	}
+
+	return findings
+}
+
+// isDuplicatePunch - Check whether the punch is within the dedupe window of the recorded punches
+func isDuplicatePunch(punchTime time.Time, punchTimes []time.Time, dedupeWindow time.Duration) bool {
+	for _, recordedTime := range punchTimes {
+		if absDuration(punchTime.Sub(recordedTime)) <= dedupeWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// setImportError - Mark the import result of the row as error
+func setImportError(result utils.Map, reason string) {
+	result[hr_common.FLD_IMPORT_STATUS] = hr_common.IMPORT_STATUS_ERROR
+	result[hr_common.FLD_REASON] = reason
+}
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
 	}
+	return duration
 }
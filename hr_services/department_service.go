@@ -161,6 +161,12 @@ func (p *departmentBaseService) Create(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	// Validate Coverage rule
+	err = validateCoverageRule(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	insertResult, err := p.daoDepartment.Create(indata)
 	if err != nil {
 		return indata, err
@@ -182,6 +188,12 @@ func (p *departmentBaseService) Update(department_id string, indata utils.Map) (
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 	delete(indata, hr_common.FLD_DEPARTMENT_ID)
 
+	// Validate Coverage rule
+	err = validateCoverageRule(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	data, err = p.daoDepartment.Update(department_id, indata)
 	log.Println("DepartmentService::Update - End ")
 	return data, err
@@ -222,3 +234,30 @@ func (p *departmentBaseService) errorReturn(err error) (DepartmentService, error
 	p.EndService()
 	return nil, err
 }
+
+// validateCoverageRule - Validate the minimum coverage rule of the department or project if given
+func validateCoverageRule(indata utils.Map) error {
+
+	if _, dataOk := indata[hr_common.FLD_MIN_COVERAGE]; dataOk {
+		minCoverage, err := utils.GetMemberDataInt(indata, hr_common.FLD_MIN_COVERAGE, true)
+		if err != nil || minCoverage < 0 {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid min_coverage",
+				ErrorDetail: "min_coverage should be zero or positive number of staffs"}
+			return err
+		}
+	}
+
+	if dataVal, dataOk := indata[hr_common.FLD_COVERAGE_ACTION]; dataOk {
+		if dataVal != hr_common.COVERAGE_ACTION_WARN && dataVal != hr_common.COVERAGE_ACTION_BLOCK {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid coverage_action",
+				ErrorDetail: "coverage_action should be either warn or block"}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,9 +1,12 @@
 package hr_services
 
 import (
+	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"github.com/zapscloud/golib-business/business_common"
 	"github.com/zapscloud/golib-dbutils/db_common"
 	"github.com/zapscloud/golib-dbutils/db_utils"
 	"github.com/zapscloud/golib-hr/hr_common"
@@ -21,6 +24,7 @@ type HolidayService interface {
 	Create(indata utils.Map) (utils.Map, error)
 	Update(holiday_id string, indata utils.Map) (utils.Map, error)
 	Delete(holiday_id string, delete_permanent bool) error
+	MigrateDateTimes(timezone string) (utils.Map, error)
 
 	BeginTransaction()
 	CommitTransaction()
@@ -106,6 +110,12 @@ func (p *holidayBaseService) List(filter string, sort string, skip int64, limit
 		return nil, err
 	}
 
+	// Render the Date in local timezone
+	holidays, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, holiday := range holidays {
+		renderHolidayDate(holiday)
+	}
+
 	log.Println("AccountService::FindAll - End ")
 	return response, nil
 }
@@ -115,6 +125,9 @@ func (p *holidayBaseService) Get(holiday_id string) (utils.Map, error) {
 	log.Printf("AccountService::FindByCode::  Begin %v", holiday_id)
 
 	data, err := p.daoHoliday.Get(holiday_id)
+	if err == nil {
+		renderHolidayDate(data)
+	}
 	log.Println("AccountService::FindByCode:: End ", err)
 	return data, err
 }
@@ -123,6 +136,9 @@ func (p *holidayBaseService) Find(filter string) (utils.Map, error) {
 	log.Println("AccountService::FindByCode::  Begin ", filter)
 
 	data, err := p.daoHoliday.Find(filter)
+	if err == nil {
+		renderHolidayDate(data)
+	}
 	log.Println("AccountService::FindByCode:: End ", data, err)
 	return data, err
 }
@@ -150,6 +166,11 @@ func (p *holidayBaseService) Create(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	err = p.validateHolidayDate(indata, time.UTC)
+	if err != nil {
+		return indata, err
+	}
+
 	insertResult, err := p.daoHoliday.Create(indata)
 	if err != nil {
 		return indata, err
@@ -172,6 +193,11 @@ func (p *holidayBaseService) Update(holiday_id string, indata utils.Map) (utils.
 	delete(indata, hr_common.FLD_HOLIDAY_ID)
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 
+	err = p.validateHolidayDate(indata, hr_common.GetTimezoneLocation(data))
+	if err != nil {
+		return indata, err
+	}
+
 	data, err = p.daoHoliday.Update(holiday_id, indata)
 	log.Println("AccountService::Update - End ")
 	return data, err
@@ -212,3 +238,93 @@ func (p *holidayBaseService) errorReturn(err error) (HolidayService, error) {
 	p.EndService()
 	return nil, err
 }
+
+// ****************************************************************
+// MigrateDateTimes - Convert the legacy local holiday_date strings,
+// which are in the given timezone, to UTC dates
+//
+// ****************************************************************
+func (p *holidayBaseService) MigrateDateTimes(timezone string) (utils.Map, error) {
+
+	log.Println("HolidayService::MigrateDateTimes - Begin", timezone)
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`{"%s":{"$type":"string"}}`, hr_common.FLD_HOLIDAY_DATE)
+	response, err := p.daoHoliday.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedCount := 0
+	failedRecords := []utils.Map{}
+
+	holidays, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, holiday := range holidays {
+		holidayId, _ := utils.GetMemberDataStr(holiday, hr_common.FLD_HOLIDAY_ID)
+
+		updateData := utils.Map{hr_common.FLD_HOLIDAY_DATE: holiday[hr_common.FLD_HOLIDAY_DATE]}
+		err = p.validateHolidayDate(updateData, loc)
+		if err == nil {
+			_, err = p.daoHoliday.Update(holidayId, updateData)
+		}
+		if err != nil {
+			failedRecords = append(failedRecords, utils.Map{hr_common.FLD_HOLIDAY_ID: holidayId, hr_common.FLD_REASON: err.Error()})
+			continue
+		}
+		migratedCount++
+	}
+
+	log.Println("HolidayService::MigrateDateTimes - End", migratedCount, len(failedRecords))
+	return utils.Map{
+		hr_common.FLD_MIGRATED_COUNT: migratedCount,
+		hr_common.FLD_FAILED_RECORDS: failedRecords,
+	}, nil
+}
+
+// validateHolidayDate - Convert the holiday_date local string to the UTC date of its midnight, the string
+// is in the business_timezone sent in indata, otherwise in the given location
+func (p *holidayBaseService) validateHolidayDate(indata utils.Map, loc *time.Location) error {
+
+	if _, dataOk := indata[business_common.FLD_BUSINESS_TIMEZONE]; dataOk {
+		businessTimezone, _ := utils.GetMemberDataStr(indata, business_common.FLD_BUSINESS_TIMEZONE)
+		tzLoc, err := time.LoadLocation(businessTimezone)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
+			return err
+		}
+		loc = tzLoc
+		delete(indata, business_common.FLD_BUSINESS_TIMEZONE)
+	}
+
+	dataVal, dataOk := indata[hr_common.FLD_HOLIDAY_DATE]
+	if !dataOk {
+		return nil
+	}
+	holidayDate, ok := hr_common.ToDateTime(dataVal, loc)
+	if !ok {
+		err := &utils.AppError{
+			ErrorCode:   "S30102",
+			ErrorMsg:    "Invalid holiday_date",
+			ErrorDetail: "holiday_date value is invalid"}
+		return err
+	}
+
+	holidayDate = time.Date(holidayDate.Year(), holidayDate.Month(), holidayDate.Day(), 0, 0, 0, 0, loc)
+	indata[hr_common.FLD_HOLIDAY_DATE] = holidayDate.UTC()
+	indata[hr_common.FLD_TIMEZONE] = loc.String()
+	return nil
+}
+
+// renderHolidayDate - Add the holiday_date in local timezone (YYYY-MM-DD) to the holiday
+func renderHolidayDate(data utils.Map) {
+
+	holidayDate, ok := hr_common.ToDateTime(data[hr_common.FLD_HOLIDAY_DATE], hr_common.GetTimezoneLocation(data))
+	if ok {
+		data[hr_common.FLD_HOLIDAY_DATE_LOCAL] = holidayDate.Format(time.DateOnly)
+	}
+}
@@ -0,0 +1,480 @@
+package hr_services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-dbutils/db_utils"
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-hr/hr_repository"
+	"github.com/zapscloud/golib-platform/platform_repository"
+	"github.com/zapscloud/golib-platform/platform_services"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// LeavePolicyService - LeavePolicies Service structure
+type LeavePolicyService interface {
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	Get(leavePolicyId string) (utils.Map, error)
+	Find(filter string) (utils.Map, error)
+	Create(indata utils.Map) (utils.Map, error)
+	Update(leavePolicyId string, indata utils.Map) (utils.Map, error)
+	Delete(leavePolicyId string, delete_permanent bool) error
+	ResolveLeaveType(staffId string, leaveTypeId string, onDate string) (utils.Map, error)
+
+	BeginTransaction()
+	CommitTransaction()
+	RollbackTransaction()
+
+	EndService()
+}
+
+// leavePolicyBaseService - LeavePolicies Service structure
+type leavePolicyBaseService struct {
+	db_utils.DatabaseService
+	dbRegion            db_utils.DatabaseService
+	daoLeavePolicy      hr_repository.LeavePolicyDao
+	daoLeaveType        hr_repository.LeaveTypeDao
+	daoStaff            hr_repository.StaffDao
+	daoStaffType        hr_repository.StaffTypeDao
+	daoDepartment       hr_repository.DepartmentDao
+	daoWorkLocation     hr_repository.WorkLocationDao
+	daoPlatformBusiness platform_repository.BusinessDao
+	child               LeavePolicyService
+	businessID          string
+}
+
+// Leave type fields the policy can override
+var leavePolicyRuleFields = []string{
+	hr_common.FLD_ANNUAL_QUOTA,
+	hr_common.FLD_MONTHLY_ACCRUAL,
+	hr_common.FLD_MAX_CARRY_FORWARD,
+	hr_common.FLD_MAX_ENCASHMENT,
+	hr_common.FLD_APPROVAL_CHAIN,
+	hr_common.FLD_COUNT_SANDWICH_HOLIDAYS,
+	hr_common.FLD_COMP_OFF_VALIDITY_DAYS,
+	hr_common.FLD_MAX_PERMISSIONS_PER_MONTH,
+	hr_common.FLD_MAX_PERMISSION_HOURS_PER_MONTH,
+	hr_common.FLD_DOCUMENT_RULES,
+}
+
+// Staff fields the policy is bound to
+var leavePolicyScopeFields = []string{
+	hr_common.FLD_STAFFTYPE_ID,
+	hr_common.FLD_DEPARTMENT_ID,
+	hr_common.FLD_WORKLOCATION_ID,
+}
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
+}
+
+func NewLeavePolicyService(props utils.Map) (LeavePolicyService, error) {
+	funcode := hr_common.GetServiceModuleCode() + "M" + "01"
+
+	log.Printf("LeavePolicyService::Start ")
+	// Verify whether the business id data passed
+	businessId, err := utils.GetMemberDataStr(props, hr_common.FLD_BUSINESS_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := leavePolicyBaseService{}
+
+	// Open Database Service
+	err = p.OpenDatabaseService(props)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open RegionDB Service
+	p.dbRegion, err = platform_services.OpenRegionDatabaseService(props)
+	if err != nil {
+		p.CloseDatabaseService()
+		return nil, err
+	}
+
+	// Assign the BusinessId
+	p.businessID = businessId
+
+	// Instantiate other services
+	p.daoLeavePolicy = hr_repository.NewLeavePolicyDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoLeaveType = hr_repository.NewLeaveTypeDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoStaffType = hr_repository.NewStaffTypeDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoDepartment = hr_repository.NewDepartmentDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoWorkLocation = hr_repository.NewWorkLocationDao(p.dbRegion.GetClient(), p.businessID)
+	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
+
+	_, err = p.daoPlatformBusiness.Get(p.businessID)
+	if err != nil {
+		err := &utils.AppError{
+			ErrorCode:   funcode + "01",
+			ErrorMsg:    "Invalid business id",
+			ErrorDetail: "Given business id is not exist"}
+		return p.errorReturn(err)
+	}
+
+	p.child = &p
+
+	return &p, nil
+}
+
+func (p *leavePolicyBaseService) EndService() {
+	p.CloseDatabaseService()
+	p.dbRegion.CloseDatabaseService()
+}
+
+// List - List All records
+func (p *leavePolicyBaseService) List(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("LeavePolicyService::FindAll - Begin")
+
+	response, err := p.daoLeavePolicy.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("LeavePolicyService::FindAll - End ")
+	return response, nil
+}
+
+// Get - Get the policy
+func (p *leavePolicyBaseService) Get(leavePolicyId string) (utils.Map, error) {
+	log.Printf("LeavePolicyService::Get::  Begin %v", leavePolicyId)
+
+	data, err := p.daoLeavePolicy.Get(leavePolicyId)
+	log.Println("LeavePolicyService::Get:: End ", err)
+	return data, err
+}
+
+func (p *leavePolicyBaseService) Find(filter string) (utils.Map, error) {
+	log.Println("LeavePolicyService::Find::  Begin ", filter)
+
+	data, err := p.daoLeavePolicy.Find(filter)
+	log.Println("LeavePolicyService::Find:: End ", data, err)
+	return data, err
+}
+
+func (p *leavePolicyBaseService) Create(indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeavePolicyService::Create - Begin")
+
+	var leavePolicyId string
+
+	dataval, dataok := indata[hr_common.FLD_LEAVE_POLICY_ID]
+	if dataok {
+		leavePolicyId = strings.ToLower(dataval.(string))
+	} else {
+		leavePolicyId = utils.GenerateUniqueId("lpol")
+		log.Println("Unique LeavePolicy ID", leavePolicyId)
+	}
+	indata[hr_common.FLD_LEAVE_POLICY_ID] = leavePolicyId
+	indata[hr_common.FLD_BUSINESS_ID] = p.businessID
+
+	_, err := p.daoLeavePolicy.Get(leavePolicyId)
+	if err == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing LeavePolicy ID !", ErrorDetail: "Given LeavePolicy ID already exist"}
+		return indata, err
+	}
+
+	// Validate the scope, effective dates & rules
+	err = p.validateLeavePolicy(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	insertResult, err := p.daoLeavePolicy.Create(indata)
+	if err != nil {
+		return indata, err
+	}
+	log.Println("LeavePolicyService::Create - End ", insertResult)
+	return indata, err
+}
+
+// Update - Update Service
+func (p *leavePolicyBaseService) Update(leavePolicyId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeavePolicyService::Update - Begin")
+
+	data, err := p.daoLeavePolicy.Get(leavePolicyId)
+	if err != nil {
+		return data, err
+	}
+
+	// Delete unique fields
+	delete(indata, hr_common.FLD_BUSINESS_ID)
+	delete(indata, hr_common.FLD_LEAVE_POLICY_ID)
+
+	// Validate the modified policy
+	err = p.validateLeavePolicy(utils.MergeMap(data, indata, true))
+	if err != nil {
+		return indata, err
+	}
+
+	data, err = p.daoLeavePolicy.Update(leavePolicyId, indata)
+	log.Println("LeavePolicyService::Update - End ")
+	return data, err
+}
+
+// Delete - Delete Service
+func (p *leavePolicyBaseService) Delete(leavePolicyId string, delete_permanent bool) error {
+
+	log.Println("LeavePolicyService::Delete - Begin", leavePolicyId)
+
+	_, err := p.daoLeavePolicy.Get(leavePolicyId)
+	if err != nil {
+		return err
+	}
+
+	if delete_permanent {
+		result, err := p.daoLeavePolicy.Delete(leavePolicyId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Delete %v", result)
+	} else {
+		indata := utils.Map{db_common.FLD_IS_DELETED: true}
+		data, err := p.daoLeavePolicy.Update(leavePolicyId, indata)
+		if err != nil {
+			return err
+		}
+		log.Println("Update for Delete Flag", data)
+	}
+
+	log.Printf("LeavePolicyService::Delete - End")
+	return nil
+}
+
+// ****************************************************************
+// ResolveLeaveType - Get the leave type with the rules of the policy
+// applicable to the staff on the date (YYYY-MM-DD)
+//
+// ****************************************************************
+func (p *leavePolicyBaseService) ResolveLeaveType(staffId string, leaveTypeId string, onDate string) (utils.Map, error) {
+
+	log.Println("LeavePolicyService::ResolveLeaveType - Begin", staffId, leaveTypeId, onDate)
+
+	_, err := time.Parse(time.DateOnly, onDate)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid onDate", ErrorDetail: "onDate should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return nil, err
+	}
+	leaveType, err := p.daoLeaveType.Get(leaveTypeId)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := getLeavePolicies(p.daoLeavePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	leaveType = resolveLeaveType(policies, staffData, leaveType, onDate)
+
+	log.Println("LeavePolicyService::ResolveLeaveType - End", leaveType[hr_common.FLD_LEAVE_POLICY_ID])
+	return leaveType, nil
+}
+
+func (p *leavePolicyBaseService) errorReturn(err error) (LeavePolicyService, error) {
+	// Close the Database Connection
+	p.EndService()
+	return nil, err
+}
+
+// validateLeavePolicy - Verify the scope refers the existing records, the effective dates are valid and
+// not overlapping with the other policy of the same scope, and the rules are for the existing leave types
+func (p *leavePolicyBaseService) validateLeavePolicy(policyData utils.Map) error {
+
+	scopeDaos := map[string]func(string) (utils.Map, error){
+		hr_common.FLD_STAFFTYPE_ID:    p.daoStaffType.Get,
+		hr_common.FLD_DEPARTMENT_ID:   p.daoDepartment.Get,
+		hr_common.FLD_WORKLOCATION_ID: p.daoWorkLocation.Get,
+	}
+	for _, key := range leavePolicyScopeFields {
+		scopeId, err := utils.GetMemberDataStr(policyData, key)
+		if err != nil || len(scopeId) == 0 {
+			continue
+		}
+		_, err = scopeDaos[key](scopeId)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid " + key, ErrorDetail: "No such " + key + " found"}
+			return err
+		}
+	}
+
+	effectiveFrom, effectiveTo, err := getPolicyPeriod(policyData)
+	if err != nil {
+		return err
+	}
+
+	rules, ok := hr_common.ToArray(policyData[hr_common.FLD_LEAVE_TYPE_RULES])
+	if !ok || len(rules) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_type_rules", ErrorDetail: "leave_type_rules should be array of rules for the leave types"}
+		return err
+	}
+	leaveTypeIds := map[string]bool{}
+	for _, ruleVal := range rules {
+		rule, ok := hr_common.ToMap(ruleVal)
+		if !ok {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_type_rules", ErrorDetail: "leave_type_rules should be array of rules for the leave types"}
+			return err
+		}
+		leaveTypeId, _ := utils.GetMemberDataStr(rule, hr_common.FLD_LEAVETYPE_ID)
+		_, err := p.daoLeaveType.Get(leaveTypeId)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_type_id", ErrorDetail: "No such leave_type_id " + leaveTypeId + " found"}
+			return err
+		}
+		if leaveTypeIds[leaveTypeId] {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Duplicate leave_type_id", ErrorDetail: "Policy has more than one rule for " + leaveTypeId}
+			return err
+		}
+		leaveTypeIds[leaveTypeId] = true
+
+		err = validateLeaveType(rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only one policy of the same scope applies on a date
+	policies, err := getLeavePolicies(p.daoLeavePolicy)
+	if err != nil {
+		return err
+	}
+	leavePolicyId, _ := utils.GetMemberDataStr(policyData, hr_common.FLD_LEAVE_POLICY_ID)
+	for _, otherPolicy := range policies {
+		otherPolicyId, _ := utils.GetMemberDataStr(otherPolicy, hr_common.FLD_LEAVE_POLICY_ID)
+		if otherPolicyId == leavePolicyId || !isSamePolicyScope(policyData, otherPolicy) {
+			continue
+		}
+		otherFrom, otherTo, err := getPolicyPeriod(otherPolicy)
+		if err != nil {
+			continue
+		}
+		if effectiveFrom <= otherTo && otherFrom <= effectiveTo {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Overlapping Policy", ErrorDetail: "Policy overlaps with the policy " + otherPolicyId + " of the same scope"}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLeavePolicies - All the leave policies of the business
+func getLeavePolicies(daoLeavePolicy hr_repository.LeavePolicyDao) ([]utils.Map, error) {
+
+	response, err := daoLeavePolicy.List("", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	policies, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	return policies, nil
+}
+
+// resolveLeaveType - Leave type with the rule of the policy applicable to the staff on the date (YYYY-MM-DD). The
+// policy applies when each of its staff_type_id, department_id & work_location_id is either not set or same as the
+// staff's, the one matching more of them wins and the later effective_from on the tie
+func resolveLeaveType(policies []utils.Map, staffData utils.Map, leaveType utils.Map, onDate string) utils.Map {
+
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+
+	var bestRule utils.Map
+	bestPolicyId := ""
+	bestMatches := -1
+	bestFrom := ""
+	for _, policy := range policies {
+		effectiveFrom, effectiveTo, err := getPolicyPeriod(policy)
+		if err != nil || onDate < effectiveFrom || onDate > effectiveTo {
+			continue
+		}
+
+		matches := 0
+		for _, key := range leavePolicyScopeFields {
+			scopeId, err := utils.GetMemberDataStr(policy, key)
+			if err != nil || len(scopeId) == 0 {
+				continue
+			}
+			staffScopeId, _ := utils.GetMemberDataStr(staffData, key)
+			if scopeId != staffScopeId {
+				matches = -1
+				break
+			}
+			matches++
+		}
+		if matches < 0 {
+			continue
+		}
+		if matches < bestMatches || (matches == bestMatches && effectiveFrom <= bestFrom) {
+			continue
+		}
+
+		rules, _ := hr_common.ToArray(policy[hr_common.FLD_LEAVE_TYPE_RULES])
+		for _, ruleVal := range rules {
+			rule, ok := hr_common.ToMap(ruleVal)
+			if ok && rule[hr_common.FLD_LEAVETYPE_ID] == leaveTypeId {
+				bestRule = rule
+				bestPolicyId, _ = utils.GetMemberDataStr(policy, hr_common.FLD_LEAVE_POLICY_ID)
+				bestMatches = matches
+				bestFrom = effectiveFrom
+				break
+			}
+		}
+	}
+	if bestRule == nil {
+		return leaveType
+	}
+
+	// Copy of the leave type with the rule applied
+	resolved := utils.Map{}
+	for key, value := range leaveType {
+		resolved[key] = value
+	}
+	for _, key := range leavePolicyRuleFields {
+		if value, dataOk := bestRule[key]; dataOk {
+			resolved[key] = value
+		}
+	}
+	resolved[hr_common.FLD_LEAVE_POLICY_ID] = bestPolicyId
+	return resolved
+}
+
+// getPolicyPeriod - Effective from and to dates (YYYY-MM-DD) of the policy, the to date is open ended when not set
+func getPolicyPeriod(policyData utils.Map) (string, string, error) {
+
+	effectiveFrom, _ := utils.GetMemberDataStr(policyData, hr_common.FLD_EFFECTIVE_FROM)
+	if _, err := time.Parse(time.DateOnly, effectiveFrom); err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid effective_from", ErrorDetail: "effective_from should be in YYYY-MM-DD format"}
+		return "", "", err
+	}
+
+	effectiveTo, err := utils.GetMemberDataStr(policyData, hr_common.FLD_EFFECTIVE_TO)
+	if err != nil || len(effectiveTo) == 0 {
+		return effectiveFrom, "9999-12-31", nil
+	}
+	if _, err := time.Parse(time.DateOnly, effectiveTo); err != nil || effectiveTo < effectiveFrom {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid effective_to", ErrorDetail: "effective_to should be in YYYY-MM-DD format and not before effective_from"}
+		return "", "", err
+	}
+	return effectiveFrom, effectiveTo, nil
+}
+
+// isSamePolicyScope - Whether the policies are bound to the same staff type, department & work location
+func isSamePolicyScope(policy1 utils.Map, policy2 utils.Map) bool {
+
+	for _, key := range leavePolicyScopeFields {
+		scopeId1, _ := utils.GetMemberDataStr(policy1, key)
+		scopeId2, _ := utils.GetMemberDataStr(policy2, key)
+		if scopeId1 != scopeId2 {
+			return false
+		}
+	}
+	return true
+}
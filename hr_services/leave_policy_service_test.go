@@ -0,0 +1,113 @@
+package hr_services
+
+import (
+	"testing"
+
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+func TestResolveLeaveType(t *testing.T) {
+
+	policy := func(policyId string, effectiveFrom string, scope utils.Map, quota int) utils.Map {
+		policyData := utils.Map{
+			hr_common.FLD_LEAVE_POLICY_ID: policyId,
+			hr_common.FLD_EFFECTIVE_FROM:  effectiveFrom,
+			hr_common.FLD_LEAVE_TYPE_RULES: []any{
+				utils.Map{hr_common.FLD_LEAVETYPE_ID: "casual", hr_common.FLD_ANNUAL_QUOTA: quota},
+			},
+		}
+		for key, value := range scope {
+			policyData[key] = value
+		}
+		return policyData
+	}
+
+	staffData := utils.Map{
+		hr_common.FLD_STAFF_ID:        "staff1",
+		hr_common.FLD_STAFFTYPE_ID:    "permanent",
+		hr_common.FLD_DEPARTMENT_ID:   "sales",
+		hr_common.FLD_WORKLOCATION_ID: "chennai",
+	}
+	leaveType := utils.Map{hr_common.FLD_LEAVETYPE_ID: "casual", hr_common.FLD_ANNUAL_QUOTA: 12}
+
+	tests := []struct {
+		name       string
+		policies   []utils.Map
+		wantPolicy string
+		wantQuota  int
+	}{
+		{
+			name:       "no policy",
+			policies:   []utils.Map{},
+			wantPolicy: "",
+			wantQuota:  12,
+		},
+		{
+			name: "scope mismatch is not applied",
+			policies: []utils.Map{
+				policy("intern", "2024-01-01", utils.Map{hr_common.FLD_STAFFTYPE_ID: "intern"}, 2),
+			},
+			wantPolicy: "",
+			wantQuota:  12,
+		},
+		{
+			name: "partial mismatch is not applied",
+			policies: []utils.Map{
+				policy("sales-mumbai", "2024-01-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales", hr_common.FLD_WORKLOCATION_ID: "mumbai"}, 8),
+			},
+			wantPolicy: "",
+			wantQuota:  12,
+		},
+		{
+			name: "partial match is applied",
+			policies: []utils.Map{
+				policy("sales", "2024-01-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales"}, 10),
+			},
+			wantPolicy: "sales",
+			wantQuota:  10,
+		},
+		{
+			name: "more specific policy wins",
+			policies: []utils.Map{
+				policy("sales-chennai", "2024-01-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales", hr_common.FLD_WORKLOCATION_ID: "chennai"}, 15),
+				policy("global", "2024-06-01", utils.Map{}, 14),
+				policy("sales", "2024-06-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales"}, 10),
+			},
+			wantPolicy: "sales-chennai",
+			wantQuota:  15,
+		},
+		{
+			name: "later effective_from wins on tie",
+			policies: []utils.Map{
+				policy("sales-2025", "2025-01-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales"}, 11),
+				policy("sales-2024", "2024-01-01", utils.Map{hr_common.FLD_DEPARTMENT_ID: "sales"}, 10),
+			},
+			wantPolicy: "sales-2025",
+			wantQuota:  11,
+		},
+		{
+			name: "policy not yet effective is not applied",
+			policies: []utils.Map{
+				policy("future", "2026-01-01", utils.Map{}, 20),
+			},
+			wantPolicy: "",
+			wantQuota:  12,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved := resolveLeaveType(test.policies, staffData, leaveType, "2025-06-15")
+
+			policyId, _ := utils.GetMemberDataStr(resolved, hr_common.FLD_LEAVE_POLICY_ID)
+			if policyId != test.wantPolicy {
+				t.Errorf("leave_policy_id = %q, want %q", policyId, test.wantPolicy)
+			}
+			quota, _ := utils.GetMemberDataInt(resolved, hr_common.FLD_ANNUAL_QUOTA, true)
+			if quota != test.wantQuota {
+				t.Errorf("annual_quota = %d, want %d", quota, test.wantQuota)
+			}
+		})
+	}
+}
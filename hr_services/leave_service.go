@@ -1,10 +1,16 @@
 package hr_services
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/zapscloud/golib-business/business_common"
 	"github.com/zapscloud/golib-dbutils/db_common"
 	"github.com/zapscloud/golib-dbutils/db_utils"
 	"github.com/zapscloud/golib-hr/hr_common"
@@ -24,6 +30,24 @@ type LeaveService interface {
 	Update(leaveId string, indata utils.Map) (utils.Map, error)
 	Delete(leaveId string, delete_permanent bool) error
 	DeleteAll(delete_permanent bool) error
+	MigrateDateTimes(timezone string) (utils.Map, error)
+	GetBalance(staffId string, leaveTypeId string) (utils.Map, error)
+	AccrueLeaves(month string) (utils.Map, error)
+	CarryForward(year int) (utils.Map, error)
+	ProcessYearEnd(year int) (utils.Map, error)
+	ListEncashments(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	MarkEncashmentPaid(encashmentId string) (utils.Map, error)
+	ExpireCompOffs(asOf string) (utils.Map, error)
+	GetPermissionUsage(staffId string, leaveTypeId string, month string) (utils.Map, error)
+	Submit(leaveId string) (utils.Map, error)
+	Approve(leaveId string, indata utils.Map) (utils.Map, error)
+	Reject(leaveId string, indata utils.Map) (utils.Map, error)
+	Cancel(leaveId string, indata utils.Map) (utils.Map, error)
+	Withdraw(leaveId string, indata utils.Map) (utils.Map, error)
+	GetTeamCalendar(teamId string, from string, to string) (utils.Map, error)
+	AddAttachment(leaveId string, indata utils.Map) (utils.Map, error)
+	GetAttachment(leaveId string, attachmentId string) (utils.Map, error)
+	RemoveAttachment(leaveId string, attachmentId string) (utils.Map, error)
 
 	BeginTransaction()
 	CommitTransaction()
@@ -40,10 +64,38 @@ type leaveBaseService struct {
 	daoPlatformBusiness platform_repository.BusinessDao
 	daoPlatformAppUser  platform_repository.AppUserDao
 	daoStaff            hr_repository.StaffDao
+	daoLeaveType        hr_repository.LeaveTypeDao
+	daoLeaveBalance     hr_repository.LeaveBalanceDao
+	daoDepartment       hr_repository.DepartmentDao
+	daoPosition         hr_repository.PositionDao
+	daoHoliday          hr_repository.HolidayDao
+	daoShiftProfile     hr_repository.ShiftProfileDao
+	daoShift            hr_repository.ShiftDao
+	daoRoster           hr_repository.RosterDao
+	daoAttendance       hr_repository.AttendanceDao
+	daoProject          hr_repository.ProjectDao
+	daoTeamLeave        hr_repository.LeaveDao
+	daoLeaveEncashment  hr_repository.LeaveEncashmentDao
+	daoLeavePolicy      hr_repository.LeavePolicyDao
+	blobStore           hr_repository.BlobStore
 
 	child      LeaveService
 	businessId string
 	staffId    string
+
+	checkAttendance   bool
+	maxAttachmentSize int
+}
+
+const (
+	DEFAULT_MAX_ATTACHMENT_SIZE_KB = 5120
+)
+
+// Allowed changes of the leave status
+var leaveStatusTransitions = map[string][]string{
+	hr_common.LEAVE_STATUS_DRAFT:    {hr_common.LEAVE_STATUS_PENDING, hr_common.LEAVE_STATUS_WITHDRAWN},
+	hr_common.LEAVE_STATUS_PENDING:  {hr_common.LEAVE_STATUS_APPROVED, hr_common.LEAVE_STATUS_REJECTED, hr_common.LEAVE_STATUS_WITHDRAWN},
+	hr_common.LEAVE_STATUS_APPROVED: {hr_common.LEAVE_STATUS_CANCELLED},
 }
 
 func init() {
@@ -88,6 +140,37 @@ func NewLeaveService(props utils.Map) (LeaveService, error) {
 	p.daoPlatformAppUser = platform_repository.NewAppUserDao(p.GetClient())
 	p.daoLeave = hr_repository.NewLeaveDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
 	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoLeaveType = hr_repository.NewLeaveTypeDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoLeaveBalance = hr_repository.NewLeaveBalanceDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoDepartment = hr_repository.NewDepartmentDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoPosition = hr_repository.NewPositionDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoHoliday = hr_repository.NewHolidayDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShiftProfile = hr_repository.NewShiftProfileDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoRoster = hr_repository.NewRosterDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoAttendance = hr_repository.NewAttendanceDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoProject = hr_repository.NewProjectDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoTeamLeave = hr_repository.NewLeaveDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoLeaveEncashment = hr_repository.NewLeaveEncashmentDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoLeavePolicy = hr_repository.NewLeavePolicyDao(p.dbRegion.GetClient(), p.businessId)
+
+	// Verify the attendance on the leave days, this is optional parameter
+	p.checkAttendance, _ = utils.GetMemberDataBool(props, hr_common.FLD_CHECK_ATTENDANCE_OVERLAP)
+
+	// Maximum size of the attachment, this is optional parameter
+	maxAttachmentSizeKb, err := utils.GetMemberDataInt(props, hr_common.FLD_MAX_ATTACHMENT_SIZE_KB, true)
+	if err != nil || maxAttachmentSizeKb <= 0 {
+		maxAttachmentSizeKb = DEFAULT_MAX_ATTACHMENT_SIZE_KB
+	}
+	p.maxAttachmentSize = maxAttachmentSizeKb * 1024
+
+	// Store for the leave attachments, this is optional parameter
+	if _, dataOk := props[hr_common.FLD_BLOB_STORE_TYPE]; dataOk {
+		p.blobStore, err = hr_repository.NewBlobStore(props, p.businessId)
+		if err != nil {
+			return p.errorReturn(err)
+		}
+	}
 
 	_, err = p.daoPlatformBusiness.Get(p.businessId)
 	if err != nil {
@@ -134,6 +217,12 @@ func (p *leaveBaseService) List(filter string, sort string, skip int64, limit in
 	// Lookup Appuser Info
 	p.lookupAppuser(response)
 
+	// Render the Date-Time in local timezone
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leave := range leaves {
+		renderLeaveDateTimes(leave)
+	}
+
 	log.Println("AccountService::FindAll - End ")
 	return response, nil
 }
@@ -143,6 +232,9 @@ func (p *leaveBaseService) Get(leaveId string) (utils.Map, error) {
 	log.Printf("AccountService::FindByCode::  Begin %v", leaveId)
 
 	data, err := p.daoLeave.Get(leaveId)
+	if err == nil {
+		renderLeaveDateTimes(data)
+	}
 	log.Println("AccountService::FindByCode:: End ", err)
 	return data, err
 }
@@ -151,6 +243,9 @@ func (p *leaveBaseService) Find(filter string) (utils.Map, error) {
 	log.Println("AccountService::FindByCode::  Begin ", filter)
 
 	data, err := p.daoLeave.Find(filter)
+	if err == nil {
+		renderLeaveDateTimes(data)
+	}
 	log.Println("AccountService::FindByCode:: End ", data, err)
 	return data, err
 }
@@ -178,13 +273,80 @@ func (p *leaveBaseService) Create(indata utils.Map) (utils.Map, error) {
 		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing Account ID !", ErrorDetail: "Given Account ID already exist"}
 		return utils.Map{}, err
 	}
-	err = p.validateDateTime(indata)
+	err = p.validateDateTime(indata, time.UTC)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Compute the days to be charged
+	err = p.computeLeaveDays(indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Verify the permission is within the shift and the monthly caps
+	err = p.validatePermission(indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Verify the leave is not overlapping with the other leaves and attendances
+	err = p.validateLeaveOverlap(indata)
 	if err != nil {
 		return utils.Map{}, err
 	}
 
+	// Leave is submitted for the approval unless saved as draft
+	status, _ := utils.GetMemberDataStr(indata, hr_common.FLD_LEAVE_STATUS)
+	if status != hr_common.LEAVE_STATUS_DRAFT {
+		status = hr_common.LEAVE_STATUS_PENDING
+	}
+	p.clearLeaveWorkflow(indata)
+	indata[hr_common.FLD_LEAVE_STATUS] = status
+	indata[hr_common.FLD_LEAVE_APPROVED] = false
+	if status == hr_common.LEAVE_STATUS_PENDING {
+		err = p.resolveApprovers(indata)
+		if err != nil {
+			return utils.Map{}, err
+		}
+
+		// Verify the minimum coverage of the teams of the staff
+		warnings, err := p.checkCoverage(indata)
+		if err != nil {
+			return utils.Map{}, err
+		}
+		if len(warnings) > 0 {
+			indata[hr_common.FLD_COVERAGE_WARNINGS] = warnings
+		}
+
+		// Verify the documents required for the leave are attached
+		err = p.validateLeaveDocuments(indata)
+		if err != nil {
+			return utils.Map{}, err
+		}
+	}
+
+	// Keep the attachments in the blob store
+	if dataVal, dataOk := indata[hr_common.FLD_ATTACHMENTS]; dataOk {
+		attachments, _ := hr_common.ToArray(dataVal)
+		storedAttachments := []any{}
+		for _, attachment := range attachments {
+			attachmentData, _ := hr_common.ToMap(attachment)
+			storedAttachment, err := p.storeLeaveAttachment(leaveId, attachmentData)
+			if err != nil {
+				// Attachments stored so far are not referred by any leave
+				p.deleteLeaveAttachments(utils.Map{hr_common.FLD_ATTACHMENTS: storedAttachments})
+				return utils.Map{}, err
+			}
+			storedAttachments = append(storedAttachments, storedAttachment)
+		}
+		indata[hr_common.FLD_ATTACHMENTS] = storedAttachments
+	}
+
 	insertResult, err := p.daoLeave.Create(indata)
 	if err != nil {
+		// Attachments are not referred by the leave
+		p.deleteLeaveAttachments(indata)
 		return utils.Map{}, err
 	}
 	log.Println("UserService::Create - End ", insertResult)
@@ -206,11 +368,63 @@ func (p *leaveBaseService) Update(leaveId string, indata utils.Map) (utils.Map,
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 	delete(indata, hr_common.FLD_STAFF_ID)
 
-	err = p.validateDateTime(indata)
+	// Status is changed with Submit/Approve/Reject/Cancel/Withdraw only
+	p.clearLeaveWorkflow(indata)
+	// Attachments are changed with AddAttachment/RemoveAttachment only
+	delete(indata, hr_common.FLD_ATTACHMENTS)
+	status := getLeaveStatus(data)
+	if status != hr_common.LEAVE_STATUS_DRAFT && status != hr_common.LEAVE_STATUS_PENDING {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Leave not editable", ErrorDetail: "Leave can be modified only in draft or pending status"}
+		return utils.Map{}, err
+	}
+
+	err = p.validateDateTime(indata, hr_common.GetTimezoneLocation(data))
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Compute the days to be charged for the modified leave
+	delete(indata, hr_common.FLD_LEAVE_DAYS)
+	delete(indata, hr_common.FLD_LEAVE_HOURS)
+	leaveData := utils.MergeMap(data, indata, true)
+	err = p.computeLeaveDays(leaveData)
+	if err != nil {
+		return utils.Map{}, err
+	}
+	indata[hr_common.FLD_LEAVE_UNIT] = leaveData[hr_common.FLD_LEAVE_UNIT]
+	indata[hr_common.FLD_LEAVE_DAYS] = leaveData[hr_common.FLD_LEAVE_DAYS]
+	if leaveHours, dataOk := leaveData[hr_common.FLD_LEAVE_HOURS]; dataOk {
+		indata[hr_common.FLD_LEAVE_HOURS] = leaveHours
+	}
+
+	// Verify the modified permission is within the shift and the monthly caps
+	err = p.validatePermission(leaveData)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Verify the modified leave is not overlapping with the other leaves and attendances
+	err = p.validateLeaveOverlap(leaveData)
 	if err != nil {
 		return utils.Map{}, err
 	}
 
+	// Modified leave goes through the approval again
+	if status == hr_common.LEAVE_STATUS_PENDING {
+		err = p.resolveApprovers(leaveData)
+		if err != nil {
+			return utils.Map{}, err
+		}
+		indata[hr_common.FLD_LEAVE_APPROVERS] = leaveData[hr_common.FLD_LEAVE_APPROVERS]
+		indata[hr_common.FLD_APPROVAL_LEVEL] = leaveData[hr_common.FLD_APPROVAL_LEVEL]
+
+		// Verify the documents required for the modified leave are attached
+		err = p.validateLeaveDocuments(leaveData)
+		if err != nil {
+			return utils.Map{}, err
+		}
+	}
+
 	data, err = p.daoLeave.Update(leaveId, indata)
 	log.Println("AccountService::Update - End ")
 	return data, err
@@ -222,7 +436,13 @@ func (p *leaveBaseService) Delete(leaveId string, delete_permanent bool) error {
 	log.Println("AccountService::Delete - Begin", leaveId)
 
 	daoLeave := p.daoLeave
-	_, err := daoLeave.Get(leaveId)
+	leaveData, err := daoLeave.Get(leaveId)
+	if err != nil {
+		return err
+	}
+
+	// Give back the balance consumed by the leave
+	err = p.reverseLeave(leaveData)
 	if err != nil {
 		return err
 	}
@@ -233,6 +453,9 @@ func (p *leaveBaseService) Delete(leaveId string, delete_permanent bool) error {
 			return err
 		}
 		log.Printf("Delete %v", result)
+
+		// Remove the attachments from the blob store
+		p.deleteLeaveAttachments(leaveData)
 	} else {
 		indata := utils.Map{db_common.FLD_IS_DELETED: true}
 		data, err := daoLeave.Update(leaveId, indata)
@@ -255,12 +478,31 @@ func (p *leaveBaseService) DeleteAll(delete_permanent bool) error {
 	log.Println("LeaveService::DeleteAll - Begin", delete_permanent)
 
 	daoLeave := p.daoLeave
+
+	// Give back the balance consumed by the leaves
+	response, err := daoLeave.List("", "", 0, 0)
+	if err != nil {
+		return err
+	}
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leaveData := range leaves {
+		err = p.reverseLeave(leaveData)
+		if err != nil {
+			return err
+		}
+	}
+
 	if delete_permanent {
 		result, err := daoLeave.DeleteMany()
 		if err != nil {
 			return err
 		}
 		log.Printf("Delete %v", result)
+
+		// Remove the attachments from the blob store
+		for _, leaveData := range leaves {
+			p.deleteLeaveAttachments(leaveData)
+		}
 	} else {
 		indata := utils.Map{db_common.FLD_IS_DELETED: true}
 		data, err := daoLeave.UpdateMany(indata)
@@ -280,61 +522,2228 @@ func (p *leaveBaseService) errorReturn(err error) (LeaveService, error) {
 	return nil, err
 }
 
-func (p *leaveBaseService) validateDateTime(indata utils.Map) error {
+// validateDateTime - Convert the leave_from/leave_to local strings to UTC dates, the strings are in the
+// business_timezone sent in indata, otherwise in the given location
+func (p *leaveBaseService) validateDateTime(indata utils.Map, loc *time.Location) error {
 
-	// Convert Leave_From string to Date Format
-	dateTime, err := utils.GetMemberDataStr(indata, hr_common.FLD_LEAVE_FROM)
-	if err == nil {
-		_, err = time.Parse(time.DateTime, dateTime)
+	if _, dataOk := indata[business_common.FLD_BUSINESS_TIMEZONE]; dataOk {
+		businessTimezone, _ := utils.GetMemberDataStr(indata, business_common.FLD_BUSINESS_TIMEZONE)
+		tzLoc, err := time.LoadLocation(businessTimezone)
 		if err != nil {
-			err = &utils.AppError{
-				ErrorCode:   "S30102",
-				ErrorMsg:    "Invalid leave_from",
-				ErrorDetail: "leave_from value is invalid"}
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
 			return err
 		}
+		loc = tzLoc
+		delete(indata, business_common.FLD_BUSINESS_TIMEZONE)
 	}
 
-	// Convert Leave_To string to Date Format
-	dateTime, err = utils.GetMemberDataStr(indata, hr_common.FLD_LEAVE_TO)
-	if err == nil {
-		_, err = time.Parse(time.DateTime, dateTime)
-		if err != nil {
-			err = &utils.AppError{
+	for _, key := range []string{hr_common.FLD_LEAVE_FROM, hr_common.FLD_LEAVE_TO} {
+		dataVal, dataOk := indata[key]
+		if !dataOk {
+			continue
+		}
+		dateTime, ok := hr_common.ToDateTime(dataVal, loc)
+		if !ok {
+			err := &utils.AppError{
 				ErrorCode:   "S30102",
-				ErrorMsg:    "Invalid leave_to",
-				ErrorDetail: "leave_to value is invalid"}
+				ErrorMsg:    "Invalid " + key,
+				ErrorDetail: key + " value is invalid"}
 			return err
 		}
+		indata[key] = dateTime.UTC()
+		indata[hr_common.FLD_TIMEZONE] = loc.String()
 	}
 	return nil
 }
 
-func (p *leaveBaseService) lookupAppuser(response utils.Map) {
+// ****************************************************************
+// MigrateDateTimes - Convert the legacy local leave_from/leave_to
+// strings, which are in the given timezone, to UTC dates
+//
+// ****************************************************************
+func (p *leaveBaseService) MigrateDateTimes(timezone string) (utils.Map, error) {
 
-	// Enumerate All staffs and lookup platform_app_user table
-	dataStaff, err := utils.GetMemberData(response, db_common.LIST_RESULT)
+	log.Println("LeaveService::MigrateDateTimes - Begin", timezone)
 
-	if err == nil {
-		staffs := dataStaff.([]utils.Map)
-		for _, staff := range staffs {
-			p.mergeUserInfo(staff)
-			//log.Println(staff)
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Timezone", ErrorDetail: "Timezone Information is invalid"}
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`{"$or":[{"%s":{"$type":"string"}},{"%s":{"$type":"string"}}]}`,
+		hr_common.FLD_LEAVE_FROM, hr_common.FLD_LEAVE_TO)
+	response, err := p.daoLeave.List(filter, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedCount := 0
+	failedRecords := []utils.Map{}
+
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leave := range leaves {
+		leaveId, _ := utils.GetMemberDataStr(leave, hr_common.FLD_LEAVE_ID)
+
+		updateData := utils.Map{}
+		for _, key := range []string{hr_common.FLD_LEAVE_FROM, hr_common.FLD_LEAVE_TO} {
+			if dataVal, isStr := leave[key].(string); isStr {
+				updateData[key] = dataVal
+			}
+		}
+		err = p.validateDateTime(updateData, loc)
+		if err == nil {
+			_, err = p.daoLeave.Update(leaveId, updateData)
+		}
+		if err != nil {
+			failedRecords = append(failedRecords, utils.Map{hr_common.FLD_LEAVE_ID: leaveId, hr_common.FLD_REASON: err.Error()})
+			continue
 		}
+		migratedCount++
 	}
+
+	log.Println("LeaveService::MigrateDateTimes - End", migratedCount, len(failedRecords))
+	return utils.Map{
+		hr_common.FLD_MIGRATED_COUNT: migratedCount,
+		hr_common.FLD_FAILED_RECORDS: failedRecords,
+	}, nil
 }
 
-func (p *leaveBaseService) mergeUserInfo(staffInfo utils.Map) {
+// ****************************************************************
+// GetBalance - Get the leave balance of the staff for the leave type
+// in the current year along with the ledger entries
+//
+// ****************************************************************
+func (p *leaveBaseService) GetBalance(staffId string, leaveTypeId string) (utils.Map, error) {
 
-	staffId, _ := utils.GetMemberDataStr(staffInfo, hr_common.FLD_STAFF_ID)
-	staffData, err := p.daoPlatformAppUser.Get(staffId)
-	if err == nil {
-		// Delete unwanted fields
-		delete(staffData, db_common.FLD_CREATED_AT)
-		delete(staffData, db_common.FLD_UPDATED_AT)
-		delete(staffData, platform_common.FLD_APP_USER_ID)
+	log.Println("LeaveService::GetBalance - Begin", staffId, leaveTypeId)
 
-		// Make it as Array for backward compatible, since all MongoDB Lookups data returned as array
-		staffInfo[hr_common.FLD_STAFF_INFO] = []utils.Map{staffData}
+	leaveType, err := p.getStaffLeaveType(staffId, leaveTypeId, time.Now().Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	if isCompOff(leaveType) {
+		return p.getCompOffBalance(staffId, leaveTypeId, time.Now().Format(time.DateOnly))
+	}
+
+	year := time.Now().Year()
+	entries, err := p.getBalanceEntries(staffId, leaveTypeId, year)
+	if err != nil {
+		return nil, err
+	}
+
+	credited, debited := sumBalanceEntries(entries)
+	response := utils.Map{
+		hr_common.FLD_STAFF_ID:        staffId,
+		hr_common.FLD_LEAVETYPE_ID:    leaveTypeId,
+		hr_common.FLD_BALANCE_YEAR:    year,
+		hr_common.FLD_CREDITED:        credited,
+		hr_common.FLD_DEBITED:         debited,
+		hr_common.FLD_BALANCE:         credited - debited,
+		hr_common.FLD_BALANCE_ENTRIES: entries,
+	}
+	if leavePolicyId, dataOk := leaveType[hr_common.FLD_LEAVE_POLICY_ID]; dataOk {
+		response[hr_common.FLD_LEAVE_POLICY_ID] = leavePolicyId
+	}
+
+	log.Println("LeaveService::GetBalance - End", credited-debited)
+	return response, nil
+}
+
+// ****************************************************************
+// AccrueLeaves - Credit the entitlements of the month (YYYY-MM) to
+// the staff, the annual quota is credited once in the year and the
+// monthly accrual once in the month. Safe to run again for a month
+//
+// ****************************************************************
+func (p *leaveBaseService) AccrueLeaves(month string) (utils.Map, error) {
+
+	log.Println("LeaveService::AccrueLeaves - Begin", month)
+
+	accrualMonth, err := time.Parse("2006-01", month)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid month", ErrorDetail: "month should be in YYYY-MM format"}
+		return nil, err
+	}
+	year := accrualMonth.Year()
+
+	entitlements, err := p.getEntitlementScope(month + "-01")
+	if err != nil {
+		return nil, err
+	}
+
+	postedCount := 0
+	for _, leaveType := range entitlements {
+		staffId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_STAFF_ID)
+		leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+
+		quota, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_ANNUAL_QUOTA)
+		if quota > 0 {
+			entryKey := fmt.Sprintf("%s:%d", hr_common.BALANCE_ENTRY_QUOTA, year)
+			posted, err := p.postBalanceEntry(staffId, leaveTypeId, year, hr_common.BALANCE_ENTRY_QUOTA, quota, entryKey, nil)
+			if err != nil {
+				return nil, err
+			}
+			if posted {
+				postedCount++
+			}
+		}
+
+		accrual, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MONTHLY_ACCRUAL)
+		if accrual > 0 {
+			entryKey := fmt.Sprintf("%s:%s", hr_common.BALANCE_ENTRY_ACCRUAL, month)
+			posted, err := p.postBalanceEntry(staffId, leaveTypeId, year, hr_common.BALANCE_ENTRY_ACCRUAL, accrual, entryKey,
+				utils.Map{hr_common.FLD_ACCRUAL_MONTH: month})
+			if err != nil {
+				return nil, err
+			}
+			if posted {
+				postedCount++
+			}
+		}
+	}
+
+	log.Println("LeaveService::AccrueLeaves - End", postedCount)
+	return utils.Map{
+		hr_common.FLD_ACCRUAL_MONTH: month,
+		hr_common.FLD_POSTED_COUNT:  postedCount,
+	}, nil
+}
+
+// ****************************************************************
+// CarryForward - Carry forward the unused balance of the year to
+// the next year, limited to max_carry_forward of the leave type.
+// The carried days are debited in the year. Safe to run again for
+// a year
+//
+// ****************************************************************
+func (p *leaveBaseService) CarryForward(year int) (utils.Map, error) {
+
+	log.Println("LeaveService::CarryForward - Begin", year)
+
+	entitlements, err := p.getEntitlementScope(fmt.Sprintf("%d-12-31", year))
+	if err != nil {
+		return nil, err
+	}
+
+	postedCount := 0
+	for _, leaveType := range entitlements {
+		staffId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_STAFF_ID)
+		leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+
+		maxCarryForward, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MAX_CARRY_FORWARD)
+		if maxCarryForward <= 0 {
+			continue
+		}
+
+		entries, err := p.getBalanceEntries(staffId, leaveTypeId, year)
+		if err != nil {
+			return nil, err
+		}
+		carryForward := math.Min(getClosingBalance(entries), maxCarryForward)
+		if carryForward <= 0 {
+			continue
+		}
+
+		// Credit in the next year and the matching debit in the year
+		carryEntries := []struct {
+			entryType string
+			balYear   int
+			days      float64
+		}{
+			{hr_common.BALANCE_ENTRY_CARRY_FORWARD, year + 1, carryForward},
+			{hr_common.BALANCE_ENTRY_CARRY_OUT, year, -carryForward},
+		}
+		for _, carryEntry := range carryEntries {
+			entryKey := fmt.Sprintf("%s:%d", carryEntry.entryType, year)
+			posted, err := p.postBalanceEntry(staffId, leaveTypeId, carryEntry.balYear, carryEntry.entryType, carryEntry.days, entryKey, nil)
+			if err != nil {
+				return nil, err
+			}
+			if posted {
+				postedCount++
+			}
+		}
+	}
+
+	log.Println("LeaveService::CarryForward - End", postedCount)
+	return utils.Map{
+		hr_common.FLD_BALANCE_YEAR: year,
+		hr_common.FLD_POSTED_COUNT: postedCount,
+	}, nil
+}
+
+// ****************************************************************
+// GetTeamCalendar - Get the staffs on leave (approved or pending) on
+// each day from and to (YYYY-MM-DD) in the department or project
+//
+// ****************************************************************
+func (p *leaveBaseService) GetTeamCalendar(teamId string, from string, to string) (utils.Map, error) {
+
+	log.Println("LeaveService::GetTeamCalendar - Begin", teamId, from, to)
+
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid from", ErrorDetail: "from date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid to", ErrorDetail: "to date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	if toDate.Before(fromDate) || toDate.Sub(fromDate).Hours()/24 >= MAX_DAILY_STATUS_DAYS {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Date Range", ErrorDetail: fmt.Sprintf("to date should be after from date and within %v days", MAX_DAILY_STATUS_DAYS)}
+		return nil, err
+	}
+
+	// Team is either the department or the project
+	teamField := hr_common.FLD_DEPARTMENT_ID
+	teamData, err := p.daoDepartment.Get(teamId)
+	if err != nil {
+		teamField = hr_common.FLD_PROJECT_ID
+		teamData, err = p.daoProject.Get(teamId)
+	}
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Team", ErrorDetail: "Given id is neither department nor project"}
+		return nil, err
+	}
+
+	memberIds, err := p.getTeamMembers(teamField, teamId)
+	if err != nil {
+		return nil, err
+	}
+	teamLeaves, err := p.getTeamLeaveDays(memberIds, fromDate, toDate, "")
+	if err != nil {
+		return nil, err
+	}
+	minCoverage, _ := utils.GetMemberDataInt(teamData, hr_common.FLD_MIN_COVERAGE, true)
+
+	teamCalendar := []utils.Map{}
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		day := date.Format(time.DateOnly)
+		staffsOnLeave := teamLeaves[day]
+		if staffsOnLeave == nil {
+			staffsOnLeave = []utils.Map{}
+		}
+		onLeaveCount := countStaffsOnLeave(staffsOnLeave, "")
+		teamCalendar = append(teamCalendar, utils.Map{
+			hr_common.FLD_DATE:              day,
+			hr_common.FLD_STAFFS_ON_LEAVE:   staffsOnLeave,
+			hr_common.FLD_ON_LEAVE_COUNT:    onLeaveCount,
+			hr_common.FLD_AVAILABLE_COUNT:   len(memberIds) - onLeaveCount,
+			hr_common.FLD_IS_BELOW_COVERAGE: len(memberIds)-onLeaveCount < int(minCoverage),
+		})
+	}
+
+	log.Println("LeaveService::GetTeamCalendar - End", len(memberIds))
+	return utils.Map{
+		teamField:                   teamId,
+		hr_common.FLD_TEAM_SIZE:     len(memberIds),
+		hr_common.FLD_MIN_COVERAGE:  minCoverage,
+		hr_common.FLD_TEAM_CALENDAR: teamCalendar,
+	}, nil
+}
+
+// ****************************************************************
+// Submit - Submit the draft leave for the approval
+//
+// ****************************************************************
+func (p *leaveBaseService) Submit(leaveId string) (utils.Map, error) {
+
+	log.Println("LeaveService::Submit - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLeaveTransition(getLeaveStatus(data), hr_common.LEAVE_STATUS_PENDING)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the documents required for the leave are attached
+	err = p.validateLeaveDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.resolveApprovers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	updateData := utils.Map{
+		hr_common.FLD_LEAVE_STATUS:     hr_common.LEAVE_STATUS_PENDING,
+		hr_common.FLD_LEAVE_APPROVERS:  data[hr_common.FLD_LEAVE_APPROVERS],
+		hr_common.FLD_APPROVAL_LEVEL:   data[hr_common.FLD_APPROVAL_LEVEL],
+		hr_common.FLD_APPROVAL_HISTORY: appendLeaveHistory(data, hr_common.LEAVE_STATUS_PENDING, staffId, utils.Map{}),
+	}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+
+	log.Println("LeaveService::Submit - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Approve - Approve the pending leave by the approver of the current
+// level, the leave is approved once all the approvers in the chain
+// approved it. acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *leaveBaseService) Approve(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeaveService::Approve - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLeaveTransition(getLeaveStatus(data), hr_common.LEAVE_STATUS_APPROVED)
+	if err != nil {
+		return nil, err
+	}
+
+	actedBy, err := validateLeaveApprover(data, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the documents required for the leave are attached
+	err = p.validateLeaveDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	approvers, _ := hr_common.ToArray(data[hr_common.FLD_LEAVE_APPROVERS])
+	level, _ := utils.GetMemberDataInt(data, hr_common.FLD_APPROVAL_LEVEL, true)
+	updateData := utils.Map{
+		hr_common.FLD_APPROVAL_HISTORY: appendLeaveHistory(data, hr_common.LEAVE_STATUS_APPROVED, actedBy, indata),
+		hr_common.FLD_APPROVAL_LEVEL:   level + 1,
+	}
+
+	if int(level+1) >= len(approvers) {
+		// Verify the minimum coverage of the teams of the staff
+		warnings, err := p.checkCoverage(data)
+		if err != nil {
+			return nil, err
+		}
+		updateData[hr_common.FLD_COVERAGE_WARNINGS] = warnings
+
+		// Final approval consumes the balance
+		err = p.debitLeave(data)
+		if err != nil {
+			return nil, err
+		}
+		updateData[hr_common.FLD_LEAVE_STATUS] = hr_common.LEAVE_STATUS_APPROVED
+		updateData[hr_common.FLD_LEAVE_APPROVED] = true
+	}
+
+	data, err = p.daoLeave.Update(leaveId, updateData)
+
+	log.Println("LeaveService::Approve - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Reject - Reject the pending leave by the approver of the current
+// level. acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *leaveBaseService) Reject(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeaveService::Reject - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLeaveTransition(getLeaveStatus(data), hr_common.LEAVE_STATUS_REJECTED)
+	if err != nil {
+		return nil, err
+	}
+
+	actedBy, err := validateLeaveApprover(data, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := utils.Map{
+		hr_common.FLD_LEAVE_STATUS:     hr_common.LEAVE_STATUS_REJECTED,
+		hr_common.FLD_LEAVE_APPROVED:   false,
+		hr_common.FLD_APPROVAL_HISTORY: appendLeaveHistory(data, hr_common.LEAVE_STATUS_REJECTED, actedBy, indata),
+	}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+
+	log.Println("LeaveService::Reject - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Cancel - Cancel the approved leave and give back the balance
+// consumed. acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *leaveBaseService) Cancel(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeaveService::Cancel - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLeaveTransition(getLeaveStatus(data), hr_common.LEAVE_STATUS_CANCELLED)
+	if err != nil {
+		return nil, err
+	}
+
+	actedBy, err := validateLeaveCanceller(data, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.reverseLeave(data)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData := utils.Map{
+		hr_common.FLD_LEAVE_STATUS:     hr_common.LEAVE_STATUS_CANCELLED,
+		hr_common.FLD_LEAVE_APPROVED:   false,
+		hr_common.FLD_APPROVAL_HISTORY: appendLeaveHistory(data, hr_common.LEAVE_STATUS_CANCELLED, actedBy, indata),
+	}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+
+	log.Println("LeaveService::Cancel - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Withdraw - Withdraw the draft or pending leave by the staff, the
+// optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *leaveBaseService) Withdraw(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeaveService::Withdraw - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateLeaveTransition(getLeaveStatus(data), hr_common.LEAVE_STATUS_WITHDRAWN)
+	if err != nil {
+		return nil, err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	updateData := utils.Map{
+		hr_common.FLD_LEAVE_STATUS:     hr_common.LEAVE_STATUS_WITHDRAWN,
+		hr_common.FLD_LEAVE_APPROVED:   false,
+		hr_common.FLD_APPROVAL_HISTORY: appendLeaveHistory(data, hr_common.LEAVE_STATUS_WITHDRAWN, staffId, indata),
+	}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+
+	log.Println("LeaveService::Withdraw - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// AddAttachment - Attach the document to the leave in draft or pending
+// status, document_type, file_name and base64 content are expected in indata
+//
+// ****************************************************************
+func (p *leaveBaseService) AddAttachment(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("LeaveService::AddAttachment - Begin", leaveId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAttachmentChange(data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, err := p.storeLeaveAttachment(leaveId, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, _ := hr_common.ToArray(data[hr_common.FLD_ATTACHMENTS])
+	updateData := utils.Map{hr_common.FLD_ATTACHMENTS: append(attachments, attachment)}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+	if err != nil {
+		// Attachment is not referred by the leave
+		p.blobStore.Delete(attachment[hr_common.FLD_ATTACHMENT_REF].(string))
+		return nil, err
+	}
+
+	log.Println("LeaveService::AddAttachment - End")
+	return data, nil
+}
+
+// ****************************************************************
+// GetAttachment - Get the document attached with the leave,
+// content is returned as base64
+//
+// ****************************************************************
+func (p *leaveBaseService) GetAttachment(leaveId string, attachmentId string) (utils.Map, error) {
+
+	log.Println("LeaveService::GetAttachment - Begin", leaveId, attachmentId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, _ := findLeaveAttachment(data, attachmentId)
+	if attachment == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Attachment", ErrorDetail: "Given attachment_id is not attached with the leave"}
+		return nil, err
+	}
+
+	if p.blobStore == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Attachment Storage", ErrorDetail: "Blob store is not configured for the service"}
+		return nil, err
+	}
+	attachmentRef, _ := utils.GetMemberDataStr(attachment, hr_common.FLD_ATTACHMENT_REF)
+	content, err := p.blobStore.Get(attachmentRef)
+	if err != nil {
+		return nil, err
+	}
+
+	response := utils.Map{hr_common.FLD_LEAVE_ID: leaveId}
+	for key, value := range attachment {
+		response[key] = value
+	}
+	response[hr_common.FLD_CONTENT] = base64.StdEncoding.EncodeToString(content)
+
+	log.Println("LeaveService::GetAttachment - End", len(content))
+	return response, nil
+}
+
+// ****************************************************************
+// RemoveAttachment - Remove the document attached with the leave
+// in draft or pending status
+//
+// ****************************************************************
+func (p *leaveBaseService) RemoveAttachment(leaveId string, attachmentId string) (utils.Map, error) {
+
+	log.Println("LeaveService::RemoveAttachment - Begin", leaveId, attachmentId)
+
+	data, err := p.daoLeave.Get(leaveId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAttachmentChange(data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, index := findLeaveAttachment(data, attachmentId)
+	if attachment == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Attachment", ErrorDetail: "Given attachment_id is not attached with the leave"}
+		return nil, err
+	}
+
+	attachments, _ := hr_common.ToArray(data[hr_common.FLD_ATTACHMENTS])
+	remaining := append([]any{}, attachments[:index]...)
+	remaining = append(remaining, attachments[index+1:]...)
+	updateData := utils.Map{hr_common.FLD_ATTACHMENTS: remaining}
+	data, err = p.daoLeave.Update(leaveId, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.blobStore != nil {
+		attachmentRef, _ := utils.GetMemberDataStr(attachment, hr_common.FLD_ATTACHMENT_REF)
+		err = p.blobStore.Delete(attachmentRef)
+		if err != nil {
+			log.Println("LeaveService::RemoveAttachment - Blob not deleted", attachmentRef, err)
+		}
+	}
+
+	log.Println("LeaveService::RemoveAttachment - End")
+	return data, nil
+}
+
+// storeLeaveAttachment - Keep the base64 content of the attachment in the blob store and
+// return the attachment with the attachment_ref in place of the content
+func (p *leaveBaseService) storeLeaveAttachment(leaveId string, indata utils.Map) (utils.Map, error) {
+
+	documentType, _ := utils.GetMemberDataStr(indata, hr_common.FLD_DOCUMENT_TYPE)
+	content, err := utils.GetMemberDataStr(indata, hr_common.FLD_CONTENT)
+	if len(documentType) == 0 || err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Attachment", ErrorDetail: "Attachment should have document_type and content"}
+		return nil, err
+	}
+
+	if p.blobStore == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Attachment Storage", ErrorDetail: "Blob store is not configured for the service"}
+		return nil, err
+	}
+
+	errAttachment := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Attachment", ErrorDetail: "Attachment should be a base64 encoded image or pdf"}
+
+	contentData, contentType, ok := hr_common.DecodeBase64Data(content)
+	if !ok {
+		return nil, errAttachment
+	}
+	if len(contentData) > p.maxAttachmentSize {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Attachment Too Large", ErrorDetail: fmt.Sprintf("Attachment size should be within %v KB", p.maxAttachmentSize/1024)}
+		return nil, err
+	}
+	if !strings.HasPrefix(contentType, "image/") && contentType != "application/pdf" {
+		return nil, errAttachment
+	}
+
+	attachmentId := utils.GenerateUniqueId("lvat")
+	attachmentRef := "leave/" + leaveId + "/" + attachmentId
+	err = p.blobStore.Put(attachmentRef, contentData)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName, _ := utils.GetMemberDataStr(indata, hr_common.FLD_FILE_NAME)
+	return utils.Map{
+		hr_common.FLD_ATTACHMENT_ID:  attachmentId,
+		hr_common.FLD_DOCUMENT_TYPE:  documentType,
+		hr_common.FLD_FILE_NAME:      fileName,
+		hr_common.FLD_CONTENT_TYPE:   contentType,
+		hr_common.FLD_ATTACHMENT_REF: attachmentRef,
+		hr_common.FLD_UPLOADED_AT:    time.Now(),
+	}, nil
+}
+
+// deleteLeaveAttachments - Remove the attachments of the leave from the blob store
+func (p *leaveBaseService) deleteLeaveAttachments(leaveData utils.Map) {
+
+	if p.blobStore == nil {
+		return
+	}
+	attachments, _ := hr_common.ToArray(leaveData[hr_common.FLD_ATTACHMENTS])
+	for _, attachment := range attachments {
+		attachmentData, _ := hr_common.ToMap(attachment)
+		attachmentRef, err := utils.GetMemberDataStr(attachmentData, hr_common.FLD_ATTACHMENT_REF)
+		if err != nil {
+			continue
+		}
+		err = p.blobStore.Delete(attachmentRef)
+		if err != nil {
+			log.Println("LeaveService::deleteLeaveAttachments - Blob not deleted", attachmentRef, err)
+		}
+	}
+}
+
+// validateLeaveDocuments - Verify the documents required by the document_rules of the leave type are attached
+func (p *leaveBaseService) validateLeaveDocuments(leaveData utils.Map) error {
+
+	if _, err := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVETYPE_ID); err != nil {
+		return nil
+	}
+	leaveType, err := p.getLeaveTypeOfLeave(leaveData)
+	if err != nil {
+		return err
+	}
+	rules, _ := hr_common.ToArray(leaveType[hr_common.FLD_DOCUMENT_RULES])
+	if len(rules) == 0 {
+		return nil
+	}
+
+	leaveDays, err := getLeaveDays(leaveData)
+	if err != nil {
+		return err
+	}
+
+	attachedTypes := map[string]bool{}
+	attachments, _ := hr_common.ToArray(leaveData[hr_common.FLD_ATTACHMENTS])
+	for _, attachment := range attachments {
+		attachmentData, _ := hr_common.ToMap(attachment)
+		documentType, _ := utils.GetMemberDataStr(attachmentData, hr_common.FLD_DOCUMENT_TYPE)
+		attachedTypes[documentType] = true
+	}
+
+	for _, rule := range rules {
+		ruleData, _ := hr_common.ToMap(rule)
+		documentType, _ := utils.GetMemberDataStr(ruleData, hr_common.FLD_DOCUMENT_TYPE)
+		aboveDays, _ := hr_common.GetMemberDataFloat(ruleData, hr_common.FLD_REQUIRED_ABOVE_DAYS)
+		if leaveDays > aboveDays && !attachedTypes[documentType] {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Document Required",
+				ErrorDetail: fmt.Sprintf("%v should be attached for the leave more than %v days", documentType, aboveDays)}
+			return err
+		}
+	}
+	return nil
+}
+
+// computeLeaveDays - Compute the days to be charged for the leave excluding the holidays and the weekly
+// offs of the staff, unless the leave type counts the sandwiched ones. Half day leave is charged 0.5 day
+// and the hourly leave in proportion to the full day
+func (p *leaveBaseService) computeLeaveDays(leaveData utils.Map) error {
+
+	unit, err := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_UNIT)
+	if err != nil || len(unit) == 0 {
+		unit = hr_common.LEAVE_UNIT_FULL_DAY
+	}
+	if unit != hr_common.LEAVE_UNIT_FULL_DAY && unit != hr_common.LEAVE_UNIT_HALF_DAY && unit != hr_common.LEAVE_UNIT_HOURLY &&
+		unit != hr_common.LEAVE_UNIT_PERMISSION {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_unit", ErrorDetail: "leave_unit should be full_day, half_day, hourly or permission"}
+		return err
+	}
+
+	leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return err
+	}
+	fromDate, toDate := getLeaveDates(leaveFrom, leaveTo)
+	if toDate.Before(fromDate) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_to", ErrorDetail: "leave_to should not be before leave_from"}
+		return err
+	}
+	if unit != hr_common.LEAVE_UNIT_FULL_DAY && !toDate.Equal(fromDate) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_to", ErrorDetail: "Half day, hourly leave and permission should be within a day"}
+		return err
+	}
+
+	// Working days of the staff within the leave
+	holidays, err := getHolidayDates(p.daoHoliday, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return err
+	}
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return err
+	}
+	weeklyOffs := getStaffWeeklyOffs(p.daoShiftProfile, staffData)
+	rosterDays, err := getRosterDays(p.daoRoster, staffId, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return err
+	}
+
+	firstWorkDate := time.Time{}
+	lastWorkDate := time.Time{}
+	workDays := 0
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		if _, isHoliday := holidays[date.Format(time.DateOnly)]; isHoliday || isRosterOffDay(rosterDays, weeklyOffs, date) {
+			continue
+		}
+		if workDays == 0 {
+			firstWorkDate = date
+		}
+		lastWorkDate = date
+		workDays++
+	}
+	if workDays == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Working Days", ErrorDetail: "Leave falls on holidays or weekly offs only"}
+		return err
+	}
+
+	leaveDays := float64(workDays)
+	switch unit {
+	case hr_common.LEAVE_UNIT_HALF_DAY:
+		leaveDays = 0.5
+	case hr_common.LEAVE_UNIT_HOURLY, hr_common.LEAVE_UNIT_PERMISSION:
+		leaveHours := leaveTo.Sub(leaveFrom).Hours()
+		if leaveHours <= 0 {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_to", ErrorDetail: "leave_to should be after leave_from for hourly leave and permission"}
+			return err
+		}
+		leaveData[hr_common.FLD_LEAVE_HOURS] = math.Round(leaveHours*100) / 100
+
+		// Hours are charged as the fraction of the staff's shift on the day
+		fullDayMinutes := float64(DEFAULT_FULL_DAY_MINUTES)
+		if shiftData, err := p.getStaffShift(staffData, leaveFrom); err == nil {
+			shiftStart, shiftEnd, err := getShiftWindowForPunch(shiftData, leaveFrom)
+			if err == nil && shiftEnd.After(shiftStart) {
+				fullDayMinutes = shiftEnd.Sub(shiftStart).Minutes()
+			}
+		}
+		leaveDays = math.Round(leaveHours*60/fullDayMinutes*100) / 100
+		if unit == hr_common.LEAVE_UNIT_PERMISSION {
+			// Permissions are tracked by the monthly caps, not in the balance
+			leaveDays = 0
+		}
+	default:
+		if leaveType, err := p.getLeaveTypeOfLeave(leaveData); err == nil {
+			countSandwich, _ := utils.GetMemberDataBool(leaveType, hr_common.FLD_COUNT_SANDWICH_HOLIDAYS)
+			if countSandwich {
+				// Holidays & weekly offs between the working days are charged too
+				leaveDays = lastWorkDate.Sub(firstWorkDate).Hours()/24 + 1
+			}
+		}
+	}
+
+	leaveData[hr_common.FLD_LEAVE_UNIT] = unit
+	leaveData[hr_common.FLD_LEAVE_DAYS] = leaveDays
+	return nil
+}
+
+// getStaffShift - Shift rostered to the staff on the day of atTime, otherwise the shift assigned (type_of_work)
+func (p *leaveBaseService) getStaffShift(staffData utils.Map, atTime time.Time) (utils.Map, error) {
+
+	staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+	shiftId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_TYPE_OF_WORK)
+	if rosterData := getRosterEntryForPunch(p.daoRoster, p.daoShift, staffId, atTime); rosterData != nil {
+		if isOff, _ := utils.GetMemberDataBool(rosterData, hr_common.FLD_IS_OFF); isOff {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Outside Shift", ErrorDetail: "Staff is off on the day as per the roster"}
+			return nil, err
+		}
+		shiftId, _ = utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID)
+	}
+	if len(shiftId) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Shift", ErrorDetail: "No shift is rostered or assigned to the staff"}
+		return nil, err
+	}
+	return p.daoShift.Get(shiftId)
+}
+
+// validateLeaveOverlap - Verify the leave is not overlapping with the other leaves of the staff which are
+// not rejected, cancelled or withdrawn. Hourly leaves on the same day overlap only when the hours overlap.
+// The attendance on the full day leave days is verified when enabled for the service
+func (p *leaveBaseService) validateLeaveOverlap(leaveData utils.Map) error {
+
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return err
+	}
+	fromDate, toDate := getLeaveDates(leaveFrom, leaveTo)
+
+	// Dates are in the timezone of each leave, hence a day added on both ends of the range
+	filter := fmt.Sprintf(`{"%s":"%s","%s":{"$ne":"%s"},"%s":{"$nin":["%s","%s","%s"]},"%s":{"$lt":%s},"%s":{"$gte":%s}}`,
+		hr_common.FLD_STAFF_ID, staffId, hr_common.FLD_LEAVE_ID, leaveId,
+		hr_common.FLD_LEAVE_STATUS, hr_common.LEAVE_STATUS_REJECTED, hr_common.LEAVE_STATUS_CANCELLED, hr_common.LEAVE_STATUS_WITHDRAWN,
+		hr_common.FLD_LEAVE_FROM, hr_common.ToDateFilter(toDate.AddDate(0, 0, 2)),
+		hr_common.FLD_LEAVE_TO, hr_common.ToDateFilter(fromDate.AddDate(0, 0, -1)))
+	response, err := p.daoLeave.List(filter, "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	isHourly := isHourlyLeave(leaveData)
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, otherLeave := range leaves {
+		otherFrom, otherTo, err := getLeavePeriod(otherLeave)
+		if err != nil {
+			continue
+		}
+
+		overlaps := false
+		if isHourly && isHourlyLeave(otherLeave) {
+			overlaps = leaveFrom.Before(otherTo) && otherFrom.Before(leaveTo)
+		} else {
+			otherFromDate, otherToDate := getLeaveDates(otherFrom, otherTo)
+			overlaps = !fromDate.After(otherToDate) && !otherFromDate.After(toDate)
+		}
+		if overlaps {
+			otherLeaveId, _ := utils.GetMemberDataStr(otherLeave, hr_common.FLD_LEAVE_ID)
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Overlapping Leave", ErrorDetail: "Leave overlaps with the leave " + otherLeaveId}
+			return err
+		}
+	}
+
+	if !p.checkAttendance || leaveData[hr_common.FLD_LEAVE_UNIT] != hr_common.LEAVE_UNIT_FULL_DAY {
+		return nil
+	}
+
+	// Attendance clocked-in within the leave days, in the timezone of the leave
+	loc := hr_common.GetTimezoneLocation(leaveData)
+	dayStart := time.Date(fromDate.Year(), fromDate.Month(), fromDate.Day(), 0, 0, 0, 0, loc)
+	dayEnd := time.Date(toDate.Year(), toDate.Month(), toDate.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	filter = fmt.Sprintf(`{"%s":"%s","%s.%s":{"$gte":%s,"$lt":%s}}`, hr_common.FLD_STAFF_ID, staffId,
+		hr_common.FLD_CLOCK_IN, hr_common.FLD_DATETIME, hr_common.ToDateFilter(dayStart), hr_common.ToDateFilter(dayEnd))
+	response, err = p.daoAttendance.List(filter, "", 0, 1)
+	if err != nil {
+		return err
+	}
+	attendances, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	if len(attendances) > 0 {
+		attendanceId, _ := utils.GetMemberDataStr(attendances[0], hr_common.FLD_ATTENDANCE_ID)
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Attendance Exist", ErrorDetail: "Leave overlaps with the attendance " + attendanceId}
+		return err
+	}
+
+	return nil
+}
+
+// validatePermission - Verify the permission is within the shift of the staff on the day and the permissions
+// of the month stay within the caps of the leave type
+func (p *leaveBaseService) validatePermission(leaveData utils.Map) error {
+
+	if leaveData[hr_common.FLD_LEAVE_UNIT] != hr_common.LEAVE_UNIT_PERMISSION {
+		return nil
+	}
+
+	leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return err
+	}
+
+	// Permission can not be verified without the shift of the staff
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return err
+	}
+	shiftData, err := p.getStaffShift(staffData, leaveFrom)
+	if err != nil {
+		return err
+	}
+	shiftStart, shiftEnd, err := getShiftWindowForPunch(shiftData, leaveFrom)
+	if err != nil {
+		return err
+	}
+	if leaveFrom.Before(shiftStart) || leaveTo.After(shiftEnd) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Outside Shift",
+			ErrorDetail: fmt.Sprintf("Permission should be within the shift from %s to %s",
+				shiftStart.Format(time.DateTime), shiftEnd.Format(time.DateTime))}
+		return err
+	}
+
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVETYPE_ID)
+	leaveType, err := p.getLeaveTypeOfLeave(leaveData)
+	if err != nil {
+		return err
+	}
+	maxCount, _ := utils.GetMemberDataInt(leaveType, hr_common.FLD_MAX_PERMISSIONS_PER_MONTH, true)
+	maxHours, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MAX_PERMISSION_HOURS_PER_MONTH)
+	if maxCount <= 0 && maxHours <= 0 {
+		return nil
+	}
+
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	permissions, err := p.getMonthPermissions(staffId, leaveTypeId, leaveFrom, leaveId)
+	if err != nil {
+		return err
+	}
+	if maxCount > 0 && len(permissions)+1 > maxCount {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Permission Limit Exceeded",
+			ErrorDetail: fmt.Sprintf("Only %v permissions are allowed in a month", maxCount)}
+		return err
+	}
+	permissionHours, _ := hr_common.GetMemberDataFloat(leaveData, hr_common.FLD_LEAVE_HOURS)
+	for _, permission := range permissions {
+		hours, _ := hr_common.GetMemberDataFloat(permission, hr_common.FLD_LEAVE_HOURS)
+		permissionHours += hours
+	}
+	if maxHours > 0 && permissionHours > maxHours {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Permission Limit Exceeded",
+			ErrorDetail: fmt.Sprintf("Only %v hours of permission are allowed in a month", maxHours)}
+		return err
+	}
+
+	return nil
+}
+
+// getMonthPermissions - Permissions of the staff for the leave type in the month of the given date, other
+// than the rejected, cancelled & withdrawn ones
+func (p *leaveBaseService) getMonthPermissions(staffId string, leaveTypeId string, onDate time.Time, excludeLeaveId string) ([]utils.Map, error) {
+
+	monthStart := time.Date(onDate.Year(), onDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	// Dates are in the timezone of each leave, hence a day added on both ends of the range
+	filter, _ := json.Marshal(utils.Map{
+		hr_common.FLD_STAFF_ID:     staffId,
+		hr_common.FLD_LEAVETYPE_ID: leaveTypeId,
+		hr_common.FLD_LEAVE_ID:     utils.Map{"$ne": excludeLeaveId},
+		hr_common.FLD_LEAVE_UNIT:   hr_common.LEAVE_UNIT_PERMISSION,
+		hr_common.FLD_LEAVE_STATUS: utils.Map{"$nin": []string{hr_common.LEAVE_STATUS_REJECTED,
+			hr_common.LEAVE_STATUS_CANCELLED, hr_common.LEAVE_STATUS_WITHDRAWN}},
+		hr_common.FLD_LEAVE_FROM: utils.Map{
+			"$gte": json.RawMessage(hr_common.ToDateFilter(monthStart.AddDate(0, 0, -1))),
+			"$lt":  json.RawMessage(hr_common.ToDateFilter(monthEnd.AddDate(0, 0, 1))),
+		},
+	})
+	response, err := p.daoTeamLeave.List(string(filter), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	month := monthStart.Format("2006-01")
+	permissions := []utils.Map{}
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leave := range leaves {
+		leaveFrom, _, err := getLeavePeriod(leave)
+		if err == nil && leaveFrom.Format("2006-01") == month {
+			permissions = append(permissions, leave)
+		}
+	}
+	return permissions, nil
+}
+
+// checkCoverage - Verify the leave keeps the department and the projects of the staff at the minimum
+// coverage, returns the days dropping below the minimum when the coverage action is to warn
+func (p *leaveBaseService) checkCoverage(leaveData utils.Map) ([]utils.Map, error) {
+
+	warnings := []utils.Map{}
+	if isHourlyLeave(leaveData) {
+		// Hourly leave & permission does not affect the coverage
+		return warnings, nil
+	}
+
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Department and the projects of the staff
+	teams := []utils.Map{}
+	if departmentId, err := utils.GetMemberDataStr(staffData, hr_common.FLD_DEPARTMENT_ID); err == nil {
+		if departmentData, err := p.daoDepartment.Get(departmentId); err == nil {
+			teams = append(teams, utils.Map{hr_common.FLD_DEPARTMENT_ID: departmentData})
+		}
+	}
+	projectIds, ok := hr_common.ToArray(staffData[hr_common.FLD_PROJECT_ID])
+	if !ok {
+		projectIds = []any{staffData[hr_common.FLD_PROJECT_ID]}
+	}
+	for _, projectId := range projectIds {
+		if projectId, ok := projectId.(string); ok {
+			if projectData, err := p.daoProject.Get(projectId); err == nil {
+				teams = append(teams, utils.Map{hr_common.FLD_PROJECT_ID: projectData})
+			}
+		}
+	}
+
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return nil, err
+	}
+	fromDate, toDate := getLeaveDates(leaveFrom, leaveTo)
+	holidays, err := getHolidayDates(p.daoHoliday, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	offDays, err := p.getStaffOffDays(staffData, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range teams {
+		for teamField, teamVal := range team {
+			teamData, _ := teamVal.(utils.Map)
+			teamId, _ := utils.GetMemberDataStr(teamData, teamField)
+			minCoverage, _ := utils.GetMemberDataInt(teamData, hr_common.FLD_MIN_COVERAGE, true)
+			if minCoverage <= 0 {
+				continue
+			}
+			action, _ := utils.GetMemberDataStr(teamData, hr_common.FLD_COVERAGE_ACTION)
+
+			memberIds, err := p.getTeamMembers(teamField, teamId)
+			if err != nil {
+				return nil, err
+			}
+			teamLeaves, err := p.getTeamLeaveDays(memberIds, fromDate, toDate, leaveId)
+			if err != nil {
+				return nil, err
+			}
+
+			for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+				day := date.Format(time.DateOnly)
+				if _, isHoliday := holidays[day]; isHoliday || offDays[day] {
+					continue
+				}
+
+				availableCount := len(memberIds) - countStaffsOnLeave(teamLeaves[day], staffId) - 1
+				if availableCount >= int(minCoverage) {
+					continue
+				}
+				if action == hr_common.COVERAGE_ACTION_BLOCK {
+					err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Coverage Conflict",
+						ErrorDetail: fmt.Sprintf("Only %d staffs available on %s in %s %s, minimum required is %d", availableCount, day, teamField, teamId, minCoverage)}
+					return nil, err
+				}
+				warnings = append(warnings, utils.Map{
+					teamField:                     teamId,
+					hr_common.FLD_DATE:            day,
+					hr_common.FLD_AVAILABLE_COUNT: availableCount,
+					hr_common.FLD_MIN_COVERAGE:    minCoverage,
+				})
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// getTeamMembers - Staff ids of the department or project, the project_id of the staff can be an array
+func (p *leaveBaseService) getTeamMembers(teamField string, teamId string) ([]string, error) {
+
+	filter, _ := json.Marshal(utils.Map{teamField: teamId})
+	response, err := p.daoStaff.List(string(filter), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIds := []string{}
+	staffs, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, staffData := range staffs {
+		staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+		memberIds = append(memberIds, staffId)
+	}
+	return memberIds, nil
+}
+
+// getTeamLeaveDays - Leaves (approved or pending, other than hourly) of the staffs on each date (YYYY-MM-DD)
+// from fromDate to toDate, excluding the given leave. Holidays and the off days of the staff are not counted
+func (p *leaveBaseService) getTeamLeaveDays(memberIds []string, fromDate time.Time, toDate time.Time, excludeLeaveId string) (map[string][]utils.Map, error) {
+
+	teamLeaves := map[string][]utils.Map{}
+	if len(memberIds) == 0 {
+		return teamLeaves, nil
+	}
+
+	// Dates are in the timezone of each leave, hence a day added on both ends of the range
+	filter, _ := json.Marshal(utils.Map{
+		hr_common.FLD_STAFF_ID: utils.Map{"$in": memberIds},
+		hr_common.FLD_LEAVE_ID: utils.Map{"$ne": excludeLeaveId},
+		hr_common.FLD_LEAVE_STATUS: utils.Map{"$nin": []string{hr_common.LEAVE_STATUS_DRAFT, hr_common.LEAVE_STATUS_REJECTED,
+			hr_common.LEAVE_STATUS_CANCELLED, hr_common.LEAVE_STATUS_WITHDRAWN}},
+		hr_common.FLD_LEAVE_UNIT: utils.Map{"$nin": []string{hr_common.LEAVE_UNIT_HOURLY, hr_common.LEAVE_UNIT_PERMISSION}},
+		hr_common.FLD_LEAVE_FROM: utils.Map{"$lt": json.RawMessage(hr_common.ToDateFilter(toDate.AddDate(0, 0, 2)))},
+		hr_common.FLD_LEAVE_TO:   utils.Map{"$gte": json.RawMessage(hr_common.ToDateFilter(fromDate.AddDate(0, 0, -1)))},
+	})
+	response, err := p.daoTeamLeave.List(string(filter), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := getHolidayDates(p.daoHoliday, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	staffOffDays := map[string]map[string]bool{}
+	leaves, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, leaveData := range leaves {
+		leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+		if err != nil {
+			continue
+		}
+		leaveFromDate, leaveToDate := getLeaveDates(leaveFrom, leaveTo)
+
+		staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+		offDays, dataOk := staffOffDays[staffId]
+		if !dataOk {
+			staffData, err := p.daoStaff.Get(staffId)
+			if err != nil {
+				continue
+			}
+			offDays, err = p.getStaffOffDays(staffData, fromDate, toDate)
+			if err != nil {
+				return nil, err
+			}
+			staffOffDays[staffId] = offDays
+		}
+
+		staffOnLeave := utils.Map{
+			hr_common.FLD_STAFF_ID:     leaveData[hr_common.FLD_STAFF_ID],
+			hr_common.FLD_LEAVE_ID:     leaveData[hr_common.FLD_LEAVE_ID],
+			hr_common.FLD_LEAVE_STATUS: getLeaveStatus(leaveData),
+			hr_common.FLD_LEAVE_UNIT:   leaveData[hr_common.FLD_LEAVE_UNIT],
+		}
+		for date := leaveFromDate; !date.After(leaveToDate); date = date.AddDate(0, 0, 1) {
+			if date.Before(fromDate) || date.After(toDate) {
+				continue
+			}
+			day := date.Format(time.DateOnly)
+			if _, isHoliday := holidays[day]; isHoliday || offDays[day] {
+				continue
+			}
+			teamLeaves[day] = append(teamLeaves[day], staffOnLeave)
+		}
+	}
+	return teamLeaves, nil
+}
+
+// getStaffOffDays - Weekly offs and the rostered off days (YYYY-MM-DD) of the staff from fromDate to toDate
+func (p *leaveBaseService) getStaffOffDays(staffData utils.Map, fromDate time.Time, toDate time.Time) (map[string]bool, error) {
+
+	staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+	rosterDays, err := getRosterDays(p.daoRoster, staffId, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	weeklyOffs := getStaffWeeklyOffs(p.daoShiftProfile, staffData)
+
+	offDays := map[string]bool{}
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		if isRosterOffDay(rosterDays, weeklyOffs, date) {
+			offDays[date.Format(time.DateOnly)] = true
+		}
+	}
+	return offDays, nil
+}
+
+// clearLeaveWorkflow - Remove the fields maintained by the approval workflow from indata
+func (p *leaveBaseService) clearLeaveWorkflow(indata utils.Map) {
+	delete(indata, hr_common.FLD_LEAVE_STATUS)
+	delete(indata, hr_common.FLD_LEAVE_APPROVED)
+	delete(indata, hr_common.FLD_LEAVE_APPROVERS)
+	delete(indata, hr_common.FLD_APPROVAL_LEVEL)
+	delete(indata, hr_common.FLD_APPROVAL_HISTORY)
+}
+
+// resolveApprovers - Resolve the approvers of the leave from the approval chain of the leave type, the
+// chain defaults to the reporting manager. Approvers not available for the staff are skipped
+func (p *leaveBaseService) resolveApprovers(leaveData utils.Map) error {
+
+	chain := []string{hr_common.APPROVER_REPORTING_MANAGER}
+	if leaveType, err := p.getLeaveTypeOfLeave(leaveData); err == nil {
+		if chainVal, ok := hr_common.ToArray(leaveType[hr_common.FLD_APPROVAL_CHAIN]); ok {
+			chain = []string{}
+			for _, roleVal := range chainVal {
+				if role, ok := roleVal.(string); ok {
+					chain = append(chain, role)
+				}
+			}
+		}
+	}
+
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return err
+	}
+
+	approvers := []utils.Map{}
+	approverIds := map[string]bool{staffId: true}
+	for _, role := range chain {
+		approverId := ""
+		switch role {
+		case hr_common.APPROVER_REPORTING_MANAGER:
+			approverId = p.getReportingManager(staffData)
+		case hr_common.APPROVER_DEPARTMENT_HEAD:
+			departmentId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_DEPARTMENT_ID)
+			if departmentData, err := p.daoDepartment.Get(departmentId); err == nil {
+				approverId, _ = utils.GetMemberDataStr(departmentData, hr_common.FLD_DEPARTMENT_HEAD)
+			}
+		}
+
+		if len(approverId) == 0 || approverIds[approverId] {
+			// Not available or approves already in another level
+			continue
+		}
+		approverIds[approverId] = true
+		approvers = append(approvers, utils.Map{
+			hr_common.FLD_APPROVER_ROLE: role,
+			hr_common.FLD_APPROVER_ID:   approverId,
+		})
+	}
+
+	leaveData[hr_common.FLD_LEAVE_APPROVERS] = approvers
+	leaveData[hr_common.FLD_APPROVAL_LEVEL] = 0
+	return nil
+}
+
+// getReportingManager - Reporting manager of the staff, otherwise the staff holding the parent position
+func (p *leaveBaseService) getReportingManager(staffData utils.Map) string {
+	return getReportingManager(p.daoStaff, p.daoPosition, staffData)
+}
+
+// ****************************************************************
+// ProcessYearEnd - Close the leave year, the unused balance is
+// carried forward up to max_carry_forward, then encashed up to
+// max_encashment and the rest lapses. Encashments are recorded in
+// the register for the payroll. Safe to run again for a year
+//
+// ****************************************************************
+func (p *leaveBaseService) ProcessYearEnd(year int) (utils.Map, error) {
+
+	log.Println("LeaveService::ProcessYearEnd - Begin", year)
+
+	entitlements, err := p.getEntitlementScope(fmt.Sprintf("%d-12-31", year))
+	if err != nil {
+		return nil, err
+	}
+
+	postedCount := 0
+	encashments := []utils.Map{}
+	for _, leaveType := range entitlements {
+		staffId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_STAFF_ID)
+		leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+
+		// Closing balance before the year end entries
+		entries, err := p.getBalanceEntries(staffId, leaveTypeId, year)
+		if err != nil {
+			return nil, err
+		}
+		closingBalance := getClosingBalance(entries)
+		if closingBalance <= 0 {
+			continue
+		}
+
+		// Carry forward, the one posted already by CarryForward is taken as is
+		carryForwardKey := fmt.Sprintf("%s:%d", hr_common.BALANCE_ENTRY_CARRY_FORWARD, year)
+		carryForward := 0.0
+		carryForwardEntry, err := p.findBalanceEntry(staffId, leaveTypeId, carryForwardKey)
+		if err == nil {
+			carryForward, _ = hr_common.GetMemberDataFloat(carryForwardEntry, hr_common.FLD_BALANCE_DAYS)
+		} else {
+			maxCarryForward, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MAX_CARRY_FORWARD)
+			carryForward = math.Min(closingBalance, math.Max(maxCarryForward, 0))
+		}
+
+		maxEncashment, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MAX_ENCASHMENT)
+		encashedDays := math.Min(math.Max(closingBalance-carryForward, 0), math.Max(maxEncashment, 0))
+		lapsedDays := math.Max(closingBalance-carryForward-encashedDays, 0)
+
+		yearEndEntries := []struct {
+			entryType string
+			balYear   int
+			days      float64
+			entryKey  string
+		}{
+			{hr_common.BALANCE_ENTRY_CARRY_FORWARD, year + 1, carryForward, carryForwardKey},
+			{hr_common.BALANCE_ENTRY_CARRY_OUT, year, -carryForward, fmt.Sprintf("%s:%d", hr_common.BALANCE_ENTRY_CARRY_OUT, year)},
+			{hr_common.BALANCE_ENTRY_ENCASHMENT, year, -encashedDays, fmt.Sprintf("%s:%d", hr_common.BALANCE_ENTRY_ENCASHMENT, year)},
+			{hr_common.BALANCE_ENTRY_LAPSE, year, -lapsedDays, fmt.Sprintf("%s:%d", hr_common.BALANCE_ENTRY_LAPSE, year)},
+		}
+		for _, yearEndEntry := range yearEndEntries {
+			if yearEndEntry.days == 0 {
+				continue
+			}
+			posted, err := p.postBalanceEntry(staffId, leaveTypeId, yearEndEntry.balYear, yearEndEntry.entryType,
+				yearEndEntry.days, yearEndEntry.entryKey, nil)
+			if err != nil {
+				return nil, err
+			}
+			if posted {
+				postedCount++
+			}
+		}
+
+		// Register for the payroll, once for the year
+		filter, _ := json.Marshal(utils.Map{
+			hr_common.FLD_STAFF_ID:     staffId,
+			hr_common.FLD_LEAVETYPE_ID: leaveTypeId,
+			hr_common.FLD_BALANCE_YEAR: year,
+		})
+		_, err = p.daoLeaveEncashment.Find(string(filter))
+		if err == nil {
+			continue
+		}
+		encashment := utils.Map{
+			hr_common.FLD_ENCASHMENT_ID:      utils.GenerateUniqueId("lenc"),
+			hr_common.FLD_BUSINESS_ID:        p.businessId,
+			hr_common.FLD_STAFF_ID:           staffId,
+			hr_common.FLD_LEAVETYPE_ID:       leaveTypeId,
+			hr_common.FLD_BALANCE_YEAR:       year,
+			hr_common.FLD_CLOSING_BALANCE:    closingBalance,
+			hr_common.FLD_CARRY_FORWARD_DAYS: carryForward,
+			hr_common.FLD_ENCASHED_DAYS:      encashedDays,
+			hr_common.FLD_LAPSED_DAYS:        lapsedDays,
+		}
+		if encashedDays > 0 {
+			encashment[hr_common.FLD_ENCASHMENT_STATUS] = hr_common.ENCASHMENT_STATUS_PENDING
+		}
+		_, err = p.daoLeaveEncashment.Create(encashment)
+		if err != nil {
+			return nil, err
+		}
+		encashments = append(encashments, encashment)
+	}
+
+	log.Println("LeaveService::ProcessYearEnd - End", postedCount, len(encashments))
+	return utils.Map{
+		hr_common.FLD_BALANCE_YEAR: year,
+		hr_common.FLD_POSTED_COUNT: postedCount,
+		hr_common.FLD_ENCASHMENTS:  encashments,
+	}, nil
+}
+
+// ****************************************************************
+// ListEncashments - List the year end encashment register
+//
+// ****************************************************************
+func (p *leaveBaseService) ListEncashments(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("LeaveService::ListEncashments - Begin")
+
+	response, err := p.daoLeaveEncashment.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("LeaveService::ListEncashments - End")
+	return response, nil
+}
+
+// ****************************************************************
+// MarkEncashmentPaid - Mark the encashment as paid by the payroll
+//
+// ****************************************************************
+func (p *leaveBaseService) MarkEncashmentPaid(encashmentId string) (utils.Map, error) {
+
+	log.Println("LeaveService::MarkEncashmentPaid - Begin", encashmentId)
+
+	data, err := p.daoLeaveEncashment.Get(encashmentId)
+	if err != nil {
+		return nil, err
+	}
+
+	status, _ := utils.GetMemberDataStr(data, hr_common.FLD_ENCASHMENT_STATUS)
+	if status != hr_common.ENCASHMENT_STATUS_PENDING {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Encashment", ErrorDetail: "Encashment is not pending for the payment"}
+		return nil, err
+	}
+
+	data, err = p.daoLeaveEncashment.Update(encashmentId, utils.Map{hr_common.FLD_ENCASHMENT_STATUS: hr_common.ENCASHMENT_STATUS_PAID})
+
+	log.Println("LeaveService::MarkEncashmentPaid - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// GetPermissionUsage - Get the permissions taken by the staff for
+// the leave type in the month (YYYY-MM) against the monthly caps
+//
+// ****************************************************************
+func (p *leaveBaseService) GetPermissionUsage(staffId string, leaveTypeId string, month string) (utils.Map, error) {
+
+	log.Println("LeaveService::GetPermissionUsage - Begin", staffId, leaveTypeId, month)
+
+	permissionMonth, err := time.Parse("2006-01", month)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid month", ErrorDetail: "month should be in YYYY-MM format"}
+		return nil, err
+	}
+	leaveType, err := p.getStaffLeaveType(staffId, leaveTypeId, permissionMonth.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := p.getMonthPermissions(staffId, leaveTypeId, permissionMonth, "")
+	if err != nil {
+		return nil, err
+	}
+	permissionHours := 0.0
+	for _, permission := range permissions {
+		hours, _ := hr_common.GetMemberDataFloat(permission, hr_common.FLD_LEAVE_HOURS)
+		permissionHours += hours
+	}
+
+	response := utils.Map{
+		hr_common.FLD_STAFF_ID:         staffId,
+		hr_common.FLD_LEAVETYPE_ID:     leaveTypeId,
+		hr_common.FLD_PERMISSION_MONTH: month,
+		hr_common.FLD_PERMISSION_COUNT: len(permissions),
+		hr_common.FLD_PERMISSION_HOURS: permissionHours,
+		hr_common.FLD_PERMISSIONS:      permissions,
+	}
+	maxCount, _ := utils.GetMemberDataInt(leaveType, hr_common.FLD_MAX_PERMISSIONS_PER_MONTH, true)
+	if maxCount > 0 {
+		response[hr_common.FLD_MAX_PERMISSIONS_PER_MONTH] = maxCount
+		remaining := maxCount - len(permissions)
+		if remaining < 0 {
+			remaining = 0
+		}
+		response[hr_common.FLD_REMAINING_PERMISSIONS] = remaining
+	}
+	maxHours, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MAX_PERMISSION_HOURS_PER_MONTH)
+	if maxHours > 0 {
+		response[hr_common.FLD_MAX_PERMISSION_HOURS_PER_MONTH] = maxHours
+		response[hr_common.FLD_REMAINING_HOURS] = math.Max(maxHours-permissionHours, 0)
+	}
+
+	log.Println("LeaveService::GetPermissionUsage - End", len(permissions), permissionHours)
+	return response, nil
+}
+
+// ****************************************************************
+// ExpireCompOffs - Lapse the unused comp-off credits expired before
+// the date asOf (YYYY-MM-DD). Safe to run again
+//
+// ****************************************************************
+func (p *leaveBaseService) ExpireCompOffs(asOf string) (utils.Map, error) {
+
+	log.Println("LeaveService::ExpireCompOffs - Begin", asOf)
+
+	_, err := time.Parse(time.DateOnly, asOf)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid asOf", ErrorDetail: "asOf date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+
+	filter, _ := json.Marshal(utils.Map{hr_common.FLD_IS_COMP_OFF: true})
+	response, err := p.daoLeaveType.List(string(filter), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	leaveTypes, _ := response[db_common.LIST_RESULT].([]utils.Map)
+
+	staffs, err := p.getScopeStaffs()
+	if err != nil {
+		return nil, err
+	}
+
+	postedCount := 0
+	for _, staffData := range staffs {
+		staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+		for _, leaveType := range leaveTypes {
+			leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+
+			entries, err := p.getBalanceEntries(staffId, leaveTypeId, 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, credit := range allocateCompOffs(entries) {
+				expiresOn, _ := utils.GetMemberDataStr(credit, hr_common.FLD_EXPIRES_ON)
+				remaining, _ := hr_common.GetMemberDataFloat(credit, hr_common.FLD_BALANCE)
+				if expiresOn >= asOf || remaining <= 0 {
+					continue
+				}
+
+				attendanceId, _ := utils.GetMemberDataStr(credit, hr_common.FLD_ATTENDANCE_ID)
+				year, _ := utils.GetMemberDataInt(credit, hr_common.FLD_BALANCE_YEAR, true)
+				entryKey := fmt.Sprintf("%s:%s", hr_common.BALANCE_ENTRY_COMP_OFF_EXPIRY, attendanceId)
+				posted, err := p.postBalanceEntry(staffId, leaveTypeId, year, hr_common.BALANCE_ENTRY_COMP_OFF_EXPIRY, -remaining, entryKey,
+					utils.Map{hr_common.FLD_ATTENDANCE_ID: attendanceId, hr_common.FLD_EXPIRES_ON: expiresOn})
+				if err != nil {
+					return nil, err
+				}
+				if posted {
+					postedCount++
+				}
+			}
+		}
+	}
+
+	log.Println("LeaveService::ExpireCompOffs - End", postedCount)
+	return utils.Map{
+		hr_common.FLD_POSTED_COUNT: postedCount,
+	}, nil
+}
+
+// getCompOffBalance - Comp-off credits of the staff still available on the date (YYYY-MM-DD), the credits
+// are not tied to the leave year as they are valid for the days from the work date
+func (p *leaveBaseService) getCompOffBalance(staffId string, leaveTypeId string, onDate string) (utils.Map, error) {
+
+	entries, err := p.getBalanceEntries(staffId, leaveTypeId, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := 0.0
+	compOffs := []utils.Map{}
+	for _, credit := range allocateCompOffs(entries) {
+		expiresOn, _ := utils.GetMemberDataStr(credit, hr_common.FLD_EXPIRES_ON)
+		remaining, _ := hr_common.GetMemberDataFloat(credit, hr_common.FLD_BALANCE)
+		if expiresOn >= onDate && remaining > 0 {
+			balance += remaining
+			compOffs = append(compOffs, credit)
+		}
+	}
+
+	credited, debited := sumBalanceEntries(entries)
+	return utils.Map{
+		hr_common.FLD_STAFF_ID:        staffId,
+		hr_common.FLD_LEAVETYPE_ID:    leaveTypeId,
+		hr_common.FLD_CREDITED:        credited,
+		hr_common.FLD_DEBITED:         debited,
+		hr_common.FLD_BALANCE:         balance,
+		hr_common.FLD_COMP_OFFS:       compOffs,
+		hr_common.FLD_BALANCE_ENTRIES: entries,
+	}, nil
+}
+
+// getScopeStaffs - Staff of the service or all the staffs
+func (p *leaveBaseService) getScopeStaffs() ([]utils.Map, error) {
+
+	if len(p.staffId) > 0 {
+		staffData, err := p.daoStaff.Get(p.staffId)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.Map{staffData}, nil
+	}
+
+	response, err := p.daoStaff.List("", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	staffs, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	return staffs, nil
+}
+
+// getEntitlementScope - Leave types having entitlements for each staff in the scope, with the rules of the
+// policy applicable to the staff on the date (YYYY-MM-DD) and the staff_id of the staff
+func (p *leaveBaseService) getEntitlementScope(onDate string) ([]utils.Map, error) {
+
+	staffs, err := p.getScopeStaffs()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.daoLeaveType.List("", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	leaveTypes, _ := response[db_common.LIST_RESULT].([]utils.Map)
+
+	policies, err := getLeavePolicies(p.daoLeavePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	entitlements := []utils.Map{}
+	for _, staffData := range staffs {
+		for _, leaveType := range leaveTypes {
+			staffLeaveType := resolveLeaveType(policies, staffData, leaveType, onDate)
+			if !hasEntitlement(staffLeaveType) {
+				continue
+			}
+			staffLeaveType = utils.MergeMap(utils.Map{}, staffLeaveType, true)
+			staffLeaveType[hr_common.FLD_STAFF_ID] = staffData[hr_common.FLD_STAFF_ID]
+			entitlements = append(entitlements, staffLeaveType)
+		}
+	}
+
+	return entitlements, nil
+}
+
+// getStaffLeaveType - Leave type with the rules of the policy applicable to the staff on the date (YYYY-MM-DD)
+func (p *leaveBaseService) getStaffLeaveType(staffId string, leaveTypeId string, onDate string) (utils.Map, error) {
+
+	leaveType, err := p.daoLeaveType.Get(leaveTypeId)
+	if err != nil {
+		return nil, err
+	}
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := getLeavePolicies(p.daoLeavePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return resolveLeaveType(policies, staffData, leaveType, onDate), nil
+}
+
+// getLeaveTypeOfLeave - Leave type of the leave with the rules of the policy applicable to the staff on the leave start date
+func (p *leaveBaseService) getLeaveTypeOfLeave(leaveData utils.Map) (utils.Map, error) {
+
+	leaveFrom, _, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return nil, err
+	}
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVETYPE_ID)
+	return p.getStaffLeaveType(staffId, leaveTypeId, leaveFrom.Format(time.DateOnly))
+}
+
+// getBalanceEntries - Ledger entries of the staff for the leave type in the year, all the years when zero
+func (p *leaveBaseService) getBalanceEntries(staffId string, leaveTypeId string, year int) ([]utils.Map, error) {
+
+	filterData := utils.Map{
+		hr_common.FLD_STAFF_ID:     staffId,
+		hr_common.FLD_LEAVETYPE_ID: leaveTypeId,
+	}
+	if year > 0 {
+		filterData[hr_common.FLD_BALANCE_YEAR] = year
+	}
+	filter, _ := json.Marshal(filterData)
+	sort := fmt.Sprintf(`{"%s":1}`, db_common.FLD_CREATED_AT)
+	response, err := p.daoLeaveBalance.List(string(filter), sort, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	entries, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	if entries == nil {
+		entries = []utils.Map{}
+	}
+	return entries, nil
+}
+
+// findBalanceEntry - Find the ledger entry of the staff for the leave type by the entry key
+func (p *leaveBaseService) findBalanceEntry(staffId string, leaveTypeId string, entryKey string) (utils.Map, error) {
+	return findLedgerEntry(p.daoLeaveBalance, staffId, leaveTypeId, entryKey)
+}
+
+// postBalanceEntry - Add the entry to the ledger unless the entry with the same key is posted already
+func (p *leaveBaseService) postBalanceEntry(staffId string, leaveTypeId string, year int, entryType string, days float64,
+	entryKey string, extraData utils.Map) (bool, error) {
+	return postLedgerEntry(p.daoLeaveBalance, p.businessId, staffId, leaveTypeId, year, entryType, days, entryKey, extraData)
+}
+
+// getLeaveLedgerState - Leave type, balance year and the net days posted so far for the leave. The leave
+// type is nil when it has no entitlements to track
+func (p *leaveBaseService) getLeaveLedgerState(leaveData utils.Map) (utils.Map, int, float64, int, error) {
+
+	if leaveData[hr_common.FLD_LEAVE_UNIT] == hr_common.LEAVE_UNIT_PERMISSION {
+		// Permissions are tracked by the monthly caps, not in the balance
+		return nil, 0, 0, 0, nil
+	}
+
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVETYPE_ID)
+	leaveType, err := p.getLeaveTypeOfLeave(leaveData)
+	if err != nil || (!hasEntitlement(leaveType) && !isCompOff(leaveType)) {
+		return nil, 0, 0, 0, nil
+	}
+
+	leaveFrom, ok := hr_common.ToDateTime(leaveData[hr_common.FLD_LEAVE_FROM], hr_common.GetTimezoneLocation(leaveData))
+	if !ok {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_from", ErrorDetail: "leave_from value is invalid"}
+		return nil, 0, 0, 0, err
+	}
+	year := leaveFrom.Year()
+
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	entries, err := p.getBalanceEntries(staffId, leaveTypeId, year)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	netDays := 0.0
+	leaveEntries := 0
+	for _, entry := range entries {
+		entryLeaveId, _ := utils.GetMemberDataStr(entry, hr_common.FLD_LEAVE_ID)
+		if entryLeaveId == leaveId {
+			days, _ := hr_common.GetMemberDataFloat(entry, hr_common.FLD_BALANCE_DAYS)
+			netDays += days
+			leaveEntries++
+		}
+	}
+	return leaveType, year, netDays, leaveEntries, nil
+}
+
+// debitLeave - Consume the balance for the approved leave, fails when the balance is not enough
+func (p *leaveBaseService) debitLeave(leaveData utils.Map) error {
+
+	leaveType, year, netDays, leaveEntries, err := p.getLeaveLedgerState(leaveData)
+	if err != nil || leaveType == nil || netDays < 0 {
+		// Not tracked or debited already
+		return err
+	}
+
+	days, err := getLeaveDays(leaveData)
+	if err != nil {
+		return err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+	balance := 0.0
+	if isCompOff(leaveType) {
+		// Credits valid on the leave start date
+		leaveFrom, _, err := getLeavePeriod(leaveData)
+		if err != nil {
+			return err
+		}
+		balanceData, err := p.getCompOffBalance(staffId, leaveTypeId, leaveFrom.Format(time.DateOnly))
+		if err != nil {
+			return err
+		}
+		balance, _ = hr_common.GetMemberDataFloat(balanceData, hr_common.FLD_BALANCE)
+	} else {
+		entries, err := p.getBalanceEntries(staffId, leaveTypeId, year)
+		if err != nil {
+			return err
+		}
+		credited, debited := sumBalanceEntries(entries)
+		balance = credited - debited
+	}
+	if balance < days {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Insufficient Leave Balance",
+			ErrorDetail: fmt.Sprintf("Leave needs %v days but the balance is %v days", days, balance)}
+		return err
+	}
+
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	entryKey := fmt.Sprintf("%s:%s:%d", hr_common.BALANCE_ENTRY_DEBIT, leaveId, leaveEntries)
+	_, err = p.postBalanceEntry(staffId, leaveTypeId, year, hr_common.BALANCE_ENTRY_DEBIT, -days, entryKey,
+		utils.Map{hr_common.FLD_LEAVE_ID: leaveId})
+	return err
+}
+
+// reverseLeave - Give back the balance consumed by the leave, if any
+func (p *leaveBaseService) reverseLeave(leaveData utils.Map) error {
+
+	leaveType, year, netDays, leaveEntries, err := p.getLeaveLedgerState(leaveData)
+	if err != nil || leaveType == nil || netDays >= 0 {
+		// Not tracked or nothing to reverse
+		return err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID)
+	leaveId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_ID)
+	leaveTypeId, _ := utils.GetMemberDataStr(leaveType, hr_common.FLD_LEAVETYPE_ID)
+	entryKey := fmt.Sprintf("%s:%s:%d", hr_common.BALANCE_ENTRY_REVERSAL, leaveId, leaveEntries)
+	_, err = p.postBalanceEntry(staffId, leaveTypeId, year, hr_common.BALANCE_ENTRY_REVERSAL, -netDays, entryKey,
+		utils.Map{hr_common.FLD_LEAVE_ID: leaveId})
+	return err
+}
+
+func (p *leaveBaseService) lookupAppuser(response utils.Map) {
+
+	// Enumerate All staffs and lookup platform_app_user table
+	dataStaff, err := utils.GetMemberData(response, db_common.LIST_RESULT)
+
+	if err == nil {
+		staffs := dataStaff.([]utils.Map)
+		for _, staff := range staffs {
+			p.mergeUserInfo(staff)
+			//log.Println(staff)
+		}
+	}
+}
+
+func (p *leaveBaseService) mergeUserInfo(staffInfo utils.Map) {
+
+	staffId, _ := utils.GetMemberDataStr(staffInfo, hr_common.FLD_STAFF_ID)
+	staffData, err := p.daoPlatformAppUser.Get(staffId)
+	if err == nil {
+		// Delete unwanted fields
+		delete(staffData, db_common.FLD_CREATED_AT)
+		delete(staffData, db_common.FLD_UPDATED_AT)
+		delete(staffData, platform_common.FLD_APP_USER_ID)
+
+		// Make it as Array for backward compatible, since all MongoDB Lookups data returned as array
+		staffInfo[hr_common.FLD_STAFF_INFO] = []utils.Map{staffData}
+	}
+}
+
+// renderLeaveDateTimes - Add the leave_from/leave_to in local timezone to the leave
+func renderLeaveDateTimes(data utils.Map) {
+
+	loc := hr_common.GetTimezoneLocation(data)
+	if localDateTime, ok := hr_common.ToLocalDateTimeStr(data[hr_common.FLD_LEAVE_FROM], loc); ok {
+		data[hr_common.FLD_LEAVE_FROM_LOCAL] = localDateTime
+	}
+	if localDateTime, ok := hr_common.ToLocalDateTimeStr(data[hr_common.FLD_LEAVE_TO], loc); ok {
+		data[hr_common.FLD_LEAVE_TO_LOCAL] = localDateTime
+	}
+}
+
+// hasEntitlement - Whether the leave type has the entitlements to track in the balance ledger
+func hasEntitlement(leaveType utils.Map) bool {
+
+	quota, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_ANNUAL_QUOTA)
+	accrual, _ := hr_common.GetMemberDataFloat(leaveType, hr_common.FLD_MONTHLY_ACCRUAL)
+	return quota > 0 || accrual > 0
+}
+
+// isCompOff - Whether the leave type is availed against the comp-off credits
+func isCompOff(leaveType utils.Map) bool {
+
+	compOff, _ := utils.GetMemberDataBool(leaveType, hr_common.FLD_IS_COMP_OFF)
+	return compOff
+}
+
+// findLedgerEntry - Find the ledger entry of the staff for the leave type by the entry key
+func findLedgerEntry(daoLeaveBalance hr_repository.LeaveBalanceDao, staffId string, leaveTypeId string, entryKey string) (utils.Map, error) {
+
+	filter, _ := json.Marshal(utils.Map{
+		hr_common.FLD_STAFF_ID:          staffId,
+		hr_common.FLD_LEAVETYPE_ID:      leaveTypeId,
+		hr_common.FLD_BALANCE_ENTRY_KEY: entryKey,
+	})
+	return daoLeaveBalance.Find(string(filter))
+}
+
+// postLedgerEntry - Add the entry to the ledger unless the entry with the same key is posted already
+func postLedgerEntry(daoLeaveBalance hr_repository.LeaveBalanceDao, businessId string, staffId string, leaveTypeId string,
+	year int, entryType string, days float64, entryKey string, extraData utils.Map) (bool, error) {
+
+	_, err := findLedgerEntry(daoLeaveBalance, staffId, leaveTypeId, entryKey)
+	if err == nil {
+		// Posted already
+		return false, nil
+	}
+
+	entry := utils.MergeMap(extraData, utils.Map{
+		hr_common.FLD_BALANCE_ENTRY_ID:   utils.GenerateUniqueId("lbal"),
+		hr_common.FLD_BUSINESS_ID:        businessId,
+		hr_common.FLD_STAFF_ID:           staffId,
+		hr_common.FLD_LEAVETYPE_ID:       leaveTypeId,
+		hr_common.FLD_BALANCE_YEAR:       year,
+		hr_common.FLD_BALANCE_ENTRY_TYPE: entryType,
+		hr_common.FLD_BALANCE_ENTRY_KEY:  entryKey,
+		hr_common.FLD_BALANCE_DAYS:       days,
+	}, true)
+	_, err = daoLeaveBalance.Create(entry)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// allocateCompOffs - Comp-off credits in the order of expiry with the days remaining (balance) after the
+// expired days and the days availed, the availed days consume the credits expiring first
+func allocateCompOffs(entries []utils.Map) []utils.Map {
+
+	credits := []utils.Map{}
+	expired := map[string]float64{}
+	availed := 0.0
+	for _, entry := range entries {
+		entryType, _ := utils.GetMemberDataStr(entry, hr_common.FLD_BALANCE_ENTRY_TYPE)
+		days, _ := hr_common.GetMemberDataFloat(entry, hr_common.FLD_BALANCE_DAYS)
+		attendanceId, _ := utils.GetMemberDataStr(entry, hr_common.FLD_ATTENDANCE_ID)
+		switch entryType {
+		case hr_common.BALANCE_ENTRY_COMP_OFF:
+			credits = append(credits, utils.Map{
+				hr_common.FLD_ATTENDANCE_ID: attendanceId,
+				hr_common.FLD_WORK_DATE:     entry[hr_common.FLD_WORK_DATE],
+				hr_common.FLD_EXPIRES_ON:    entry[hr_common.FLD_EXPIRES_ON],
+				hr_common.FLD_BALANCE_YEAR:  entry[hr_common.FLD_BALANCE_YEAR],
+				hr_common.FLD_COMP_OFF_DAYS: days,
+				hr_common.FLD_BALANCE:       days,
+			})
+		case hr_common.BALANCE_ENTRY_COMP_OFF_EXPIRY:
+			expired[attendanceId] -= days
+		default:
+			availed -= days
+		}
+	}
+	sort.SliceStable(credits, func(i, j int) bool {
+		expiresOn1, _ := utils.GetMemberDataStr(credits[i], hr_common.FLD_EXPIRES_ON)
+		expiresOn2, _ := utils.GetMemberDataStr(credits[j], hr_common.FLD_EXPIRES_ON)
+		return expiresOn1 < expiresOn2
+	})
+
+	for _, credit := range credits {
+		attendanceId, _ := utils.GetMemberDataStr(credit, hr_common.FLD_ATTENDANCE_ID)
+		remaining, _ := hr_common.GetMemberDataFloat(credit, hr_common.FLD_BALANCE)
+		remaining = math.Max(remaining-expired[attendanceId], 0)
+		if availed > 0 {
+			consumed := math.Min(remaining, availed)
+			remaining -= consumed
+			availed -= consumed
+		}
+		credit[hr_common.FLD_BALANCE] = remaining
+	}
+	return credits
+}
+
+// sumBalanceEntries - Total days credited and debited in the ledger entries
+func sumBalanceEntries(entries []utils.Map) (float64, float64) {
+
+	credited := 0.0
+	debited := 0.0
+	for _, entry := range entries {
+		days, _ := hr_common.GetMemberDataFloat(entry, hr_common.FLD_BALANCE_DAYS)
+		if days >= 0 {
+			credited += days
+		} else {
+			debited -= days
+		}
+	}
+	return credited, debited
+}
+
+// getClosingBalance - Balance of the year before the year end entries (carry forward out, encashment & lapse)
+func getClosingBalance(entries []utils.Map) float64 {
+
+	yearEntries := []utils.Map{}
+	for _, entry := range entries {
+		entryType, _ := utils.GetMemberDataStr(entry, hr_common.FLD_BALANCE_ENTRY_TYPE)
+		if entryType != hr_common.BALANCE_ENTRY_CARRY_OUT && entryType != hr_common.BALANCE_ENTRY_ENCASHMENT &&
+			entryType != hr_common.BALANCE_ENTRY_LAPSE {
+			yearEntries = append(yearEntries, entry)
+		}
+	}
+	credited, debited := sumBalanceEntries(yearEntries)
+	return credited - debited
+}
+
+// getLeaveDays - Days charged for the leave, calendar days from leave_from to leave_to when not computed
+func getLeaveDays(leaveData utils.Map) (float64, error) {
+
+	if days, err := hr_common.GetMemberDataFloat(leaveData, hr_common.FLD_LEAVE_DAYS); err == nil {
+		return days, nil
+	}
+
+	leaveFrom, leaveTo, err := getLeavePeriod(leaveData)
+	if err != nil {
+		return 0, err
+	}
+	fromDate, toDate := getLeaveDates(leaveFrom, leaveTo)
+	if toDate.Before(fromDate) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_to", ErrorDetail: "leave_to should not be before leave_from"}
+		return 0, err
+	}
+	return toDate.Sub(fromDate).Hours()/24 + 1, nil
+}
+
+// getLeaveStatus - Status of the leave, derived from leave_approved for the leaves created before the workflow
+func getLeaveStatus(leaveData utils.Map) string {
+
+	status, err := utils.GetMemberDataStr(leaveData, hr_common.FLD_LEAVE_STATUS)
+	if err == nil && len(status) > 0 {
+		return status
+	}
+	if approved, _ := utils.GetMemberDataBool(leaveData, hr_common.FLD_LEAVE_APPROVED); approved {
+		return hr_common.LEAVE_STATUS_APPROVED
+	}
+	return hr_common.LEAVE_STATUS_PENDING
+}
+
+// validateLeaveTransition - Verify the leave can move from the status to the new status
+func validateLeaveTransition(fromStatus string, toStatus string) error {
+
+	for _, allowedStatus := range leaveStatusTransitions[fromStatus] {
+		if allowedStatus == toStatus {
+			return nil
+		}
+	}
+	err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Status Change",
+		ErrorDetail: fmt.Sprintf("Leave in %s status can not be %s", fromStatus, toStatus)}
+	return err
+}
+
+// validateLeaveApprover - Verify acted_by is the approver of the current level, any approver other than
+// the applicant is allowed when no approvers resolved for the leave
+func validateLeaveApprover(leaveData utils.Map, indata utils.Map) (string, error) {
+
+	actedBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	if err != nil || len(actedBy) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No acted_by", ErrorDetail: "Approver id should be sent in acted_by"}
+		return "", err
+	}
+	if staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID); actedBy == staffId {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Staff can't approve or reject the own leave"}
+		return "", err
+	}
+
+	approvers, _ := hr_common.ToArray(leaveData[hr_common.FLD_LEAVE_APPROVERS])
+	level, _ := utils.GetMemberDataInt(leaveData, hr_common.FLD_APPROVAL_LEVEL, true)
+	if int(level) >= len(approvers) {
+		return actedBy, nil
+	}
+
+	approver, _ := hr_common.ToMap(approvers[level])
+	approverId, _ := utils.GetMemberDataStr(approver, hr_common.FLD_APPROVER_ID)
+	if approverId != actedBy {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Leave is awaiting the action of " + approverId}
+		return "", err
+	}
+	return actedBy, nil
+}
+
+// validateLeaveCanceller - Verify acted_by is either the applicant or one of the approvers of the leave
+func validateLeaveCanceller(leaveData utils.Map, indata utils.Map) (string, error) {
+
+	actedBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	if err != nil || len(actedBy) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No acted_by", ErrorDetail: "Id of the staff cancelling the leave should be sent in acted_by"}
+		return "", err
+	}
+	if staffId, _ := utils.GetMemberDataStr(leaveData, hr_common.FLD_STAFF_ID); actedBy == staffId {
+		return actedBy, nil
+	}
+
+	approvers, _ := hr_common.ToArray(leaveData[hr_common.FLD_LEAVE_APPROVERS])
+	for _, approverVal := range approvers {
+		approver, _ := hr_common.ToMap(approverVal)
+		if approverId, _ := utils.GetMemberDataStr(approver, hr_common.FLD_APPROVER_ID); approverId == actedBy {
+			return actedBy, nil
+		}
+	}
+	err = &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not Allowed", ErrorDetail: "Leave can be cancelled only by the staff or the approvers of the leave"}
+	return "", err
+}
+
+// appendLeaveHistory - Approval history of the leave with the new action added
+func appendLeaveHistory(leaveData utils.Map, action string, actedBy string, indata utils.Map) []any {
+
+	history, _ := hr_common.ToArray(leaveData[hr_common.FLD_APPROVAL_HISTORY])
+	level, _ := utils.GetMemberDataInt(leaveData, hr_common.FLD_APPROVAL_LEVEL, true)
+
+	entry := utils.Map{
+		hr_common.FLD_ACTION:         action,
+		hr_common.FLD_APPROVAL_LEVEL: level,
+		hr_common.FLD_ACTED_BY:       actedBy,
+		hr_common.FLD_ACTED_AT:       time.Now(),
+	}
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		entry[hr_common.FLD_REMARKS] = remarks
+	}
+	return append(history, entry)
+}
+
+// findLeaveAttachment - Attachment of the leave with the attachment_id and its index
+func findLeaveAttachment(leaveData utils.Map, attachmentId string) (utils.Map, int) {
+
+	attachments, _ := hr_common.ToArray(leaveData[hr_common.FLD_ATTACHMENTS])
+	for index, attachment := range attachments {
+		attachmentData, _ := hr_common.ToMap(attachment)
+		if id, _ := utils.GetMemberDataStr(attachmentData, hr_common.FLD_ATTACHMENT_ID); id == attachmentId {
+			return attachmentData, index
+		}
+	}
+	return nil, -1
+}
+
+// validateAttachmentChange - Attachments can be changed only in draft or pending status
+func validateAttachmentChange(leaveData utils.Map) error {
+
+	status := getLeaveStatus(leaveData)
+	if status != hr_common.LEAVE_STATUS_DRAFT && status != hr_common.LEAVE_STATUS_PENDING {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Leave not editable", ErrorDetail: "Attachments can be changed only in draft or pending status"}
+		return err
+	}
+	return nil
+}
+
+// isHourlyLeave - Whether the leave is for the hours within a day, hourly leave or permission
+func isHourlyLeave(leaveData utils.Map) bool {
+
+	unit := leaveData[hr_common.FLD_LEAVE_UNIT]
+	return unit == hr_common.LEAVE_UNIT_HOURLY || unit == hr_common.LEAVE_UNIT_PERMISSION
+}
+
+// getLeavePeriod - Start and end time of the leave in its timezone, the end is same as the start when not given
+func getLeavePeriod(leaveData utils.Map) (time.Time, time.Time, error) {
+
+	loc := hr_common.GetTimezoneLocation(leaveData)
+	leaveFrom, ok := hr_common.ToDateTime(leaveData[hr_common.FLD_LEAVE_FROM], loc)
+	if !ok {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid leave_from", ErrorDetail: "leave_from value is invalid"}
+		return leaveFrom, leaveFrom, err
+	}
+	leaveTo, ok := hr_common.ToDateTime(leaveData[hr_common.FLD_LEAVE_TO], loc)
+	if !ok {
+		leaveTo = leaveFrom
+	}
+	return leaveFrom, leaveTo, nil
+}
+
+// getLeaveDates - Local dates of the leave start and end, as UTC midnight to compare across the timezones
+func getLeaveDates(leaveFrom time.Time, leaveTo time.Time) (time.Time, time.Time) {
+
+	fromDate := time.Date(leaveFrom.Year(), leaveFrom.Month(), leaveFrom.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(leaveTo.Year(), leaveTo.Month(), leaveTo.Day(), 0, 0, 0, 0, time.UTC)
+	return fromDate, toDate
+}
+
+// countStaffsOnLeave - Number of distinct staffs in the leaves, excluding the given staff
+func countStaffsOnLeave(staffsOnLeave []utils.Map, excludeStaffId string) int {
+
+	staffIds := map[string]bool{}
+	for _, staffOnLeave := range staffsOnLeave {
+		staffId, _ := utils.GetMemberDataStr(staffOnLeave, hr_common.FLD_STAFF_ID)
+		if staffId != excludeStaffId {
+			staffIds[staffId] = true
+		}
 	}
+	return len(staffIds)
 }
@@ -163,6 +163,12 @@ func (p *leaveTypeBaseService) Create(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	// Validate Entitlement & Approval chain values
+	err = validateLeaveType(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	insertResult, err := p.daoLeaveType.Create(indata)
 	if err != nil {
 		return indata, err
@@ -184,6 +190,12 @@ func (p *leaveTypeBaseService) Update(LeaveType_id string, indata utils.Map) (ut
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 	delete(indata, hr_common.FLD_LEAVETYPE_ID)
 
+	// Validate Entitlement & Approval chain values
+	err = validateLeaveType(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	data, err = p.daoLeaveType.Update(LeaveType_id, indata)
 	log.Println("LeaveTypeService::Update - End ")
 	return data, err
@@ -224,3 +236,104 @@ func (p *leaveTypeBaseService) errorReturn(err error) (LeaveTypeService, error)
 	p.EndService()
 	return nil, err
 }
+
+// validateLeaveType - Validate the entitlement, permission & approval chain values of the leave type or the
+// leave type rule of the policy
+func validateLeaveType(indata utils.Map) error {
+
+	for _, key := range []string{hr_common.FLD_ANNUAL_QUOTA, hr_common.FLD_MONTHLY_ACCRUAL, hr_common.FLD_MAX_CARRY_FORWARD,
+		hr_common.FLD_MAX_ENCASHMENT} {
+		if _, dataOk := indata[key]; !dataOk {
+			continue
+		}
+		days, err := hr_common.GetMemberDataFloat(indata, key)
+		if err != nil || days < 0 {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid " + key,
+				ErrorDetail: key + " should be zero or positive number of days"}
+			return err
+		}
+	}
+
+	// Comp-off is credited for the attendance on the holidays & weekly offs, not by the entitlements
+	if compOff, _ := utils.GetMemberDataBool(indata, hr_common.FLD_IS_COMP_OFF); compOff {
+		if hasEntitlement(indata) {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid is_comp_off",
+				ErrorDetail: "Comp-off leave type should not have annual_quota or monthly_accrual"}
+			return err
+		}
+	}
+	if _, dataOk := indata[hr_common.FLD_COMP_OFF_VALIDITY_DAYS]; dataOk {
+		validityDays, err := utils.GetMemberDataInt(indata, hr_common.FLD_COMP_OFF_VALIDITY_DAYS, true)
+		if err != nil || validityDays <= 0 {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid " + hr_common.FLD_COMP_OFF_VALIDITY_DAYS,
+				ErrorDetail: hr_common.FLD_COMP_OFF_VALIDITY_DAYS + " should be positive number of days"}
+			return err
+		}
+	}
+
+	// Permission caps are count & hours in a month
+	for _, key := range []string{hr_common.FLD_MAX_PERMISSIONS_PER_MONTH, hr_common.FLD_MAX_PERMISSION_HOURS_PER_MONTH} {
+		if _, dataOk := indata[key]; !dataOk {
+			continue
+		}
+		limit, err := hr_common.GetMemberDataFloat(indata, key)
+		if err != nil || limit < 0 {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid " + key,
+				ErrorDetail: key + " should be zero or positive number"}
+			return err
+		}
+	}
+
+	// Document rules are array of document_type with required_above_days
+	if dataVal, dataOk := indata[hr_common.FLD_DOCUMENT_RULES]; dataOk {
+		rules, ok := hr_common.ToArray(dataVal)
+		if !ok {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid " + hr_common.FLD_DOCUMENT_RULES,
+				ErrorDetail: hr_common.FLD_DOCUMENT_RULES + " should be array of document_type with required_above_days"}
+			return err
+		}
+		for _, rule := range rules {
+			ruleData, ok := hr_common.ToMap(rule)
+			if !ok {
+				ruleData = utils.Map{}
+			}
+			documentType, _ := utils.GetMemberDataStr(ruleData, hr_common.FLD_DOCUMENT_TYPE)
+			aboveDays, err := hr_common.GetMemberDataFloat(ruleData, hr_common.FLD_REQUIRED_ABOVE_DAYS)
+			if len(documentType) == 0 || err != nil || aboveDays < 0 {
+				err := &utils.AppError{
+					ErrorCode:   "S30102",
+					ErrorMsg:    "Invalid " + hr_common.FLD_DOCUMENT_RULES,
+					ErrorDetail: hr_common.FLD_DOCUMENT_RULES + " should be array of document_type with required_above_days"}
+				return err
+			}
+		}
+	}
+
+	// Validate Approval chain if given
+	if dataVal, dataOk := indata[hr_common.FLD_APPROVAL_CHAIN]; dataOk {
+		chain, ok := hr_common.ToArray(dataVal)
+		if !ok {
+			chain = []any{dataVal}
+		}
+		for _, role := range chain {
+			if role != hr_common.APPROVER_REPORTING_MANAGER && role != hr_common.APPROVER_DEPARTMENT_HEAD {
+				err := &utils.AppError{
+					ErrorCode:   "S30102",
+					ErrorMsg:    "Invalid approval_chain",
+					ErrorDetail: "approval_chain should be array of reporting_manager and department_head"}
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -150,6 +150,12 @@ func (p *projectBaseService) Create(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	// Validate Coverage rule
+	err = validateCoverageRule(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	insertResult, err := p.daoProject.Create(indata)
 	if err != nil {
 		return indata, err
@@ -172,6 +178,12 @@ func (p *projectBaseService) Update(projectId string, indata utils.Map) (utils.M
 	delete(indata, hr_common.FLD_PROJECT_ID)
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 
+	// Validate Coverage rule
+	err = validateCoverageRule(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	data, err = p.daoProject.Update(projectId, indata)
 	log.Println("ProjectService::Update - End ")
 	return data, err
@@ -0,0 +1,562 @@
+package hr_services
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-dbutils/db_utils"
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-hr/hr_repository"
+	"github.com/zapscloud/golib-platform/platform_repository"
+	"github.com/zapscloud/golib-platform/platform_services"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// RegularizationService - Attendance Regularization Service structure
+type RegularizationService interface {
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	Get(regularizationId string) (utils.Map, error)
+	Find(filter string) (utils.Map, error)
+	Create(indata utils.Map) (utils.Map, error)
+	Update(regularizationId string, indata utils.Map) (utils.Map, error)
+	Delete(regularizationId string, delete_permanent bool) error
+	Approve(regularizationId string, indata utils.Map) (utils.Map, error)
+	Reject(regularizationId string, indata utils.Map) (utils.Map, error)
+
+	BeginTransaction()
+	CommitTransaction()
+	RollbackTransaction()
+
+	EndService()
+}
+
+// regularizationBaseService - Attendance Regularization Service structure
+type regularizationBaseService struct {
+	db_utils.DatabaseService
+	dbRegion            db_utils.DatabaseService
+	daoRegularization   hr_repository.RegularizationDao
+	daoAttendance       hr_repository.AttendanceDao
+	daoPlatformBusiness platform_repository.BusinessDao
+	daoStaff            hr_repository.StaffDao
+	daoShift            hr_repository.ShiftDao
+	daoPosition         hr_repository.PositionDao
+
+	child      RegularizationService
+	businessId string
+	staffId    string
+}
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
+}
+
+func NewRegularizationService(props utils.Map) (RegularizationService, error) {
+	funcode := hr_common.GetServiceModuleCode() + "M" + "01"
+
+	log.Printf("RegularizationService::Start")
+
+	// Verify whether the business id data passed
+	businessId, err := utils.GetMemberDataStr(props, hr_common.FLD_BUSINESS_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := regularizationBaseService{}
+
+	// Open Database Service
+	err = p.OpenDatabaseService(props)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open RegionDB Service
+	p.dbRegion, err = platform_services.OpenRegionDatabaseService(props)
+	if err != nil {
+		p.CloseDatabaseService()
+		return nil, err
+	}
+
+	// Verify whether the User id data passed, this is optional parameter
+	staffId, _ := utils.GetMemberDataStr(props, hr_common.FLD_STAFF_ID)
+
+	// Assign the BusinessId & StaffId
+	p.businessId = businessId
+	p.staffId = staffId
+
+	// Instantiate other services
+	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
+	p.daoRegularization = hr_repository.NewRegularizationDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoAttendance = hr_repository.NewAttendanceDao(p.dbRegion.GetClient(), p.businessId, p.staffId)
+	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoPosition = hr_repository.NewPositionDao(p.dbRegion.GetClient(), p.businessId)
+
+	_, err = p.daoPlatformBusiness.Get(p.businessId)
+	if err != nil {
+		err := &utils.AppError{
+			ErrorCode:   funcode + "01",
+			ErrorMsg:    "Invalid business id",
+			ErrorDetail: "Given business id is not exist"}
+		return p.errorReturn(err)
+	}
+
+	// Verify the Staff Exist
+	if len(staffId) > 0 {
+		_, err = p.daoStaff.Get(staffId)
+		if err != nil {
+			err := &utils.AppError{
+				ErrorCode:   funcode + "01",
+				ErrorMsg:    "Invalid StaffId",
+				ErrorDetail: "Given StaffId is not exist"}
+			return p.errorReturn(err)
+		}
+	}
+
+	p.child = &p
+
+	return &p, nil
+}
+
+func (p *regularizationBaseService) EndService() {
+	p.CloseDatabaseService()
+	p.dbRegion.CloseDatabaseService()
+}
+
+// List - List All records
+func (p *regularizationBaseService) List(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("RegularizationService::FindAll - Begin")
+
+	response, err := p.daoRegularization.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("RegularizationService::FindAll - End ")
+	return response, nil
+}
+
+// Get - Find By Code
+func (p *regularizationBaseService) Get(regularizationId string) (utils.Map, error) {
+	log.Printf("RegularizationService::Get::  Begin %v", regularizationId)
+
+	data, err := p.daoRegularization.Get(regularizationId)
+	log.Println("RegularizationService::Get:: End ", err)
+	return data, err
+}
+
+func (p *regularizationBaseService) Find(filter string) (utils.Map, error) {
+	log.Println("RegularizationService::Find::  Begin ", filter)
+
+	data, err := p.daoRegularization.Find(filter)
+	log.Println("RegularizationService::Find:: End ", data, err)
+	return data, err
+}
+
+// ***********************************************************
+// Create - Request the corrected Clock-In and/or Clock-Out
+// of an attendance, without attendance_id a new attendance
+// will be created for the missing punches on approval
+//
+// ***********************************************************
+func (p *regularizationBaseService) Create(indata utils.Map) (utils.Map, error) {
+
+	log.Println("RegularizationService::Create - Begin")
+
+	var regularizationId string
+
+	dataval, dataok := indata[hr_common.FLD_REGULARIZATION_ID]
+	if dataok {
+		regularizationId = strings.ToLower(dataval.(string))
+	} else {
+		regularizationId = utils.GenerateUniqueId("regl")
+		log.Println("Unique Regularization ID", regularizationId)
+	}
+
+	// StaffId from the service takes precedence over the one sent in indata
+	staffId := p.staffId
+	if len(staffId) == 0 {
+		staffId, _ = utils.GetMemberDataStr(indata, hr_common.FLD_STAFF_ID)
+	}
+	_, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return utils.Map{}, err
+	}
+
+	indata[hr_common.FLD_REGULARIZATION_ID] = regularizationId
+	indata[hr_common.FLD_BUSINESS_ID] = p.businessId
+	indata[hr_common.FLD_STAFF_ID] = staffId
+	indata[hr_common.FLD_REGULARIZATION_STATUS] = hr_common.REGULARIZATION_STATUS_PENDING
+
+	// Audit fields are set only by Approve/Reject
+	delete(indata, hr_common.FLD_ORIGINAL_CLOCK_IN)
+	delete(indata, hr_common.FLD_ORIGINAL_CLOCK_OUT)
+	delete(indata, hr_common.FLD_ACTED_BY)
+	delete(indata, hr_common.FLD_ACTED_AT)
+
+	_, err = p.daoRegularization.Get(regularizationId)
+	if err == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing Regularization ID !", ErrorDetail: "Given Regularization ID already exist"}
+		return utils.Map{}, err
+	}
+
+	err = p.validateRequest(indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	insertResult, err := p.daoRegularization.Create(indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+	log.Println("RegularizationService::Create - End ", insertResult)
+	return indata, err
+}
+
+// ***********************************************************
+// Update - Update the request, allowed only while it is pending
+//
+// ***********************************************************
+func (p *regularizationBaseService) Update(regularizationId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("RegularizationService::Update - Begin")
+
+	data, err := p.getPendingRequest(regularizationId)
+	if err != nil {
+		return data, err
+	}
+
+	// Delete key fields
+	delete(indata, hr_common.FLD_REGULARIZATION_ID)
+	delete(indata, hr_common.FLD_BUSINESS_ID)
+	delete(indata, hr_common.FLD_STAFF_ID)
+	delete(indata, hr_common.FLD_REGULARIZATION_STATUS)
+	delete(indata, hr_common.FLD_ORIGINAL_CLOCK_IN)
+	delete(indata, hr_common.FLD_ORIGINAL_CLOCK_OUT)
+	delete(indata, hr_common.FLD_ACTED_BY)
+	delete(indata, hr_common.FLD_ACTED_AT)
+
+	err = p.validateRequest(utils.MergeMap(data, indata, true))
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	data, err = p.daoRegularization.Update(regularizationId, indata)
+	log.Println("RegularizationService::Update - End ")
+	return data, err
+}
+
+// Delete - Delete Service
+func (p *regularizationBaseService) Delete(regularizationId string, delete_permanent bool) error {
+
+	log.Println("RegularizationService::Delete - Begin", regularizationId)
+
+	daoRegularization := p.daoRegularization
+	_, err := daoRegularization.Get(regularizationId)
+	if err != nil {
+		return err
+	}
+
+	if delete_permanent {
+		result, err := daoRegularization.Delete(regularizationId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Delete %v", result)
+	} else {
+		indata := utils.Map{db_common.FLD_IS_DELETED: true}
+		data, err := daoRegularization.Update(regularizationId, indata)
+		if err != nil {
+			return err
+		}
+		log.Println("Update for Delete Flag", data)
+	}
+
+	log.Printf("RegularizationService::Delete - End")
+	return nil
+}
+
+// ***********************************************************
+// Approve - Approve the request and apply the corrected punches
+// to the attendance, the values before the correction are kept
+// in the request as original_clock_in/original_clock_out
+//
+// ***********************************************************
+func (p *regularizationBaseService) Approve(regularizationId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("RegularizationService::Approve - Begin", regularizationId)
+
+	data, err := p.getPendingRequest(regularizationId)
+	if err != nil {
+		return data, err
+	}
+
+	actedBy, err := p.validateApprover(data, indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(data, hr_common.FLD_STAFF_ID)
+	requestedClockIn, _ := hr_common.ToMap(data[hr_common.FLD_REQUESTED_CLOCK_IN])
+	requestedClockOut, _ := hr_common.ToMap(data[hr_common.FLD_REQUESTED_CLOCK_OUT])
+
+	attendanceId, _ := utils.GetMemberDataStr(data, hr_common.FLD_ATTENDANCE_ID)
+	isNewAttendance := utils.IsEmpty(attendanceId)
+
+	var attendanceData utils.Map
+	if isNewAttendance {
+		// Attendance for the missing punches
+		attendanceId = utils.GenerateUniqueId("atten")
+		attendanceData = utils.Map{
+			hr_common.FLD_ATTENDANCE_ID: attendanceId,
+			hr_common.FLD_BUSINESS_ID:   p.businessId,
+			hr_common.FLD_STAFF_ID:      staffId,
+		}
+	} else {
+		attendanceData, err = p.daoAttendance.Get(attendanceId)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid AttendanceId", ErrorDetail: "No such AttendanceId found"}
+			return utils.Map{}, err
+		}
+	}
+
+	// Keep the original values for audit
+	auditData := utils.Map{}
+	if dataVal, dataOk := attendanceData[hr_common.FLD_CLOCK_IN]; dataOk {
+		auditData[hr_common.FLD_ORIGINAL_CLOCK_IN] = dataVal
+	}
+	if dataVal, dataOk := attendanceData[hr_common.FLD_CLOCK_OUT]; dataOk {
+		auditData[hr_common.FLD_ORIGINAL_CLOCK_OUT] = dataVal
+	}
+
+	// Apply the requested values over the existing punches, the requested date_time is in the
+	// timezone sent along or in the timezone of the existing punch, otherwise in UTC
+	for key, requestedData := range map[string]utils.Map{
+		hr_common.FLD_CLOCK_IN:  requestedClockIn,
+		hr_common.FLD_CLOCK_OUT: requestedClockOut} {
+		if requestedData == nil {
+			continue
+		}
+		punchData, _ := hr_common.ToMap(attendanceData[key])
+		punchData = utils.MergeMap(punchData, requestedData, true)
+		err = normalizePunchDateTime(punchData, time.UTC)
+		if err != nil {
+			return utils.Map{}, err
+		}
+		attendanceData[key] = punchData
+	}
+	attendanceData[hr_common.FLD_IS_REGULARIZED] = true
+	attendanceData[hr_common.FLD_REGULARIZATION_ID] = regularizationId
+
+	// Recompute Worked time with the corrected punches
+	err = computeAttendanceMetrics(p.daoShift, attendanceData)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	if isNewAttendance {
+		_, err = p.daoAttendance.Create(attendanceData)
+	} else {
+		_, err = p.daoAttendance.Update(attendanceId, attendanceData)
+	}
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	// Corrected Clock-Out closes the open session of the staff
+	if _, dataOk := attendanceData[hr_common.FLD_CLOCK_OUT]; dataOk {
+		err = clearStaffOpenSession(p.daoStaff, staffId, attendanceId)
+		if err != nil {
+			return utils.Map{}, err
+		}
+	}
+
+	auditData[hr_common.FLD_ATTENDANCE_ID] = attendanceId
+	auditData[hr_common.FLD_REGULARIZATION_STATUS] = hr_common.REGULARIZATION_STATUS_APPROVED
+	auditData[hr_common.FLD_ACTED_BY] = actedBy
+	auditData[hr_common.FLD_ACTED_AT] = time.Now()
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		auditData[hr_common.FLD_REMARKS] = remarks
+	}
+
+	data, err = p.daoRegularization.Update(regularizationId, auditData)
+
+	log.Println("RegularizationService::Approve - End", err)
+	return data, err
+}
+
+// ***********************************************************
+// Reject - Reject the request, attendance is not changed
+//
+// ***********************************************************
+func (p *regularizationBaseService) Reject(regularizationId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("RegularizationService::Reject - Begin", regularizationId)
+
+	data, err := p.getPendingRequest(regularizationId)
+	if err != nil {
+		return data, err
+	}
+
+	actedBy, err := p.validateApprover(data, indata)
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	rejectData := utils.Map{
+		hr_common.FLD_REGULARIZATION_STATUS: hr_common.REGULARIZATION_STATUS_REJECTED,
+		hr_common.FLD_ACTED_BY:              actedBy,
+		hr_common.FLD_ACTED_AT:              time.Now(),
+	}
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		rejectData[hr_common.FLD_REMARKS] = remarks
+	}
+
+	data, err = p.daoRegularization.Update(regularizationId, rejectData)
+
+	log.Println("RegularizationService::Reject - End", err)
+	return data, err
+}
+
+func (p *regularizationBaseService) errorReturn(err error) (RegularizationService, error) {
+	// Close the Database Connection
+	p.EndService()
+	return nil, err
+}
+
+// validateApprover - Verify the acted_by is the reporting manager of the staff, any other staff
+// is allowed when the staff has no manager. The staff can't act on the own request
+func (p *regularizationBaseService) validateApprover(requestData utils.Map, indata utils.Map) (string, error) {
+
+	actedBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	if err != nil || len(actedBy) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No acted_by", ErrorDetail: "Approver id should be sent in acted_by"}
+		return "", err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(requestData, hr_common.FLD_STAFF_ID)
+	if actedBy == staffId {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Staff can't approve or reject the own request"}
+		return "", err
+	}
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		return "", err
+	}
+	managerId := getReportingManager(p.daoStaff, p.daoPosition, staffData)
+	if len(managerId) > 0 && managerId != actedBy {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Request is awaiting the action of " + managerId}
+		return "", err
+	}
+
+	return actedBy, nil
+}
+
+// getPendingRequest - Get the request which is not approved/rejected yet
+func (p *regularizationBaseService) getPendingRequest(regularizationId string) (utils.Map, error) {
+
+	data, err := p.daoRegularization.Get(regularizationId)
+	if err != nil {
+		return data, err
+	}
+
+	status, _ := utils.GetMemberDataStr(data, hr_common.FLD_REGULARIZATION_STATUS)
+	if status != hr_common.REGULARIZATION_STATUS_PENDING {
+		err := &utils.AppError{
+			ErrorCode:   "S30102",
+			ErrorMsg:    "Regularization Closed",
+			ErrorDetail: "Regularization request is already " + status}
+		return utils.Map{}, err
+	}
+
+	return data, nil
+}
+
+// validateRequest - Verify the reason, the requested punches and the attendance of the request
+func (p *regularizationBaseService) validateRequest(indata utils.Map) error {
+
+	_, err := utils.GetMemberDataStr(indata, hr_common.FLD_REASON)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No reason", ErrorDetail: "Reason for the regularization should be sent"}
+		return err
+	}
+
+	requestedClockIn, clockInOk := hr_common.ToMap(indata[hr_common.FLD_REQUESTED_CLOCK_IN])
+	requestedClockOut, clockOutOk := hr_common.ToMap(indata[hr_common.FLD_REQUESTED_CLOCK_OUT])
+	if !clockInOk && !clockOutOk {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Punches", ErrorDetail: "requested_clock_in or requested_clock_out should be sent"}
+		return err
+	}
+
+	for fieldName, punchData := range map[string]utils.Map{
+		hr_common.FLD_REQUESTED_CLOCK_IN:  requestedClockIn,
+		hr_common.FLD_REQUESTED_CLOCK_OUT: requestedClockOut} {
+		if punchData == nil {
+			continue
+		}
+		if _, err := getPunchDateTime(punchData); err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid " + fieldName, ErrorDetail: fieldName + " date_time value is invalid"}
+			return err
+		}
+	}
+
+	attendanceId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_ATTENDANCE_ID)
+	if utils.IsEmpty(attendanceId) {
+		// Both punches needed to create the missing attendance
+		if !clockInOk || !clockOutOk {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Incomplete Punches",
+				ErrorDetail: "requested_clock_in and requested_clock_out should be sent without attendance_id"}
+			return err
+		}
+		return nil
+	}
+
+	attendanceData, err := p.daoAttendance.Get(attendanceId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid AttendanceId", ErrorDetail: "No such AttendanceId found"}
+		return err
+	}
+
+	staffId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_STAFF_ID)
+	attendanceStaffId, _ := utils.GetMemberDataStr(attendanceData, hr_common.FLD_STAFF_ID)
+	if attendanceStaffId != staffId {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid AttendanceId", ErrorDetail: "Attendance does not belong to the staff"}
+		return err
+	}
+
+	return nil
+}
+
+// getReportingManager - Reporting manager of the staff, otherwise the staff holding the parent position
+func getReportingManager(daoStaff hr_repository.StaffDao, daoPosition hr_repository.PositionDao, staffData utils.Map) string {
+
+	managerId, err := utils.GetMemberDataStr(staffData, hr_common.FLD_REPORTING_TO)
+	if err == nil && len(managerId) > 0 {
+		return managerId
+	}
+
+	positionId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_POSITION_ID)
+	positionData, err := daoPosition.Get(positionId)
+	if err != nil {
+		return ""
+	}
+	parentPositionId, err := utils.GetMemberDataStr(positionData, hr_common.FLD_REPORTS_TO)
+	if err != nil || len(parentPositionId) == 0 {
+		return ""
+	}
+
+	filter, _ := json.Marshal(utils.Map{hr_common.FLD_POSITION_ID: parentPositionId})
+	managerData, err := daoStaff.Find(string(filter))
+	if err != nil {
+		return ""
+	}
+	managerId, _ = utils.GetMemberDataStr(managerData, hr_common.FLD_STAFF_ID)
+	return managerId
+}
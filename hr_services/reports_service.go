@@ -112,6 +112,9 @@ func (p *reportsBaseService) GetAttendanceSummary(filter string, aggr string, so
 	// Lookup Appuser Info
 	p.lookupAppuser(response)
 
+	// Local date-time of the punches
+	renderSummaryDateTimes(response)
+
 	log.Println("ReportsService::GetAttendanceSummary - End")
 	return response, nil
 }
@@ -142,6 +145,25 @@ func (p *reportsBaseService) lookupAppuser(response utils.Map) {
 	}
 }
 
+// renderSummaryDateTimes - Add the local_date_time to the punches of the attendances grouped in the summary,
+// the attendances are not grouped when no aggregation is given
+func renderSummaryDateTimes(response utils.Map) {
+
+	recs, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, rec := range recs {
+		docs, ok := hr_common.ToArray(rec[hr_common.FLD_GROUP_DOCS])
+		if !ok {
+			renderPunchDateTimes(rec)
+			continue
+		}
+		for _, doc := range docs {
+			if docData, ok := hr_common.ToMap(doc); ok {
+				renderPunchDateTimes(docData)
+			}
+		}
+	}
+}
+
 func (p *reportsBaseService) mergeUserInfo(staffInfo utils.Map) {
 
 	staffId, _ := utils.GetMemberDataStr(staffInfo, hr_common.FLD_STAFF_ID)
@@ -0,0 +1,583 @@
+package hr_services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/zapscloud/golib-dbutils/db_common"
+	"github.com/zapscloud/golib-dbutils/db_utils"
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-hr/hr_repository"
+	"github.com/zapscloud/golib-platform/platform_repository"
+	"github.com/zapscloud/golib-platform/platform_services"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// RosterService - Rosters Service structure
+type RosterService interface {
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	Get(rosterId string) (utils.Map, error)
+	Find(filter string) (utils.Map, error)
+	Delete(rosterId string, delete_permanent bool) error
+	Generate(staffId string, from string, to string) (utils.Map, error)
+	Override(staffId string, rosterDate string, indata utils.Map) (utils.Map, error)
+	ClearOverride(staffId string, rosterDate string) (utils.Map, error)
+	GetStaffRoster(staffId string, from string, to string) (utils.Map, error)
+
+	BeginTransaction()
+	CommitTransaction()
+	RollbackTransaction()
+
+	EndService()
+}
+
+// rosterBaseService - Rosters Service structure
+type rosterBaseService struct {
+	db_utils.DatabaseService
+	dbRegion            db_utils.DatabaseService
+	daoRoster           hr_repository.RosterDao
+	daoStaff            hr_repository.StaffDao
+	daoShift            hr_repository.ShiftDao
+	daoShiftProfile     hr_repository.ShiftProfileDao
+	daoPlatformBusiness platform_repository.BusinessDao
+	child               RosterService
+	businessId          string
+}
+
+const (
+	MAX_ROSTER_DAYS = 366
+)
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
+}
+
+func NewRosterService(props utils.Map) (RosterService, error) {
+	funcode := hr_common.GetServiceModuleCode() + "M" + "01"
+
+	log.Printf("RosterService::Start ")
+	// Verify whether the business id data passed
+	businessId, err := utils.GetMemberDataStr(props, hr_common.FLD_BUSINESS_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := rosterBaseService{}
+
+	// Open Database Service
+	err = p.OpenDatabaseService(props)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open RegionDB Service
+	p.dbRegion, err = platform_services.OpenRegionDatabaseService(props)
+	if err != nil {
+		p.CloseDatabaseService()
+		return nil, err
+	}
+
+	// Assign the BusinessId
+	p.businessId = businessId
+
+	// Instantiate other services
+	p.daoRoster = hr_repository.NewRosterDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShiftProfile = hr_repository.NewShiftProfileDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
+
+	_, err = p.daoPlatformBusiness.Get(p.businessId)
+	if err != nil {
+		err := &utils.AppError{
+			ErrorCode:   funcode + "01",
+			ErrorMsg:    "Invalid business id",
+			ErrorDetail: "Given business id is not exist"}
+		return p.errorReturn(err)
+	}
+
+	p.child = &p
+
+	return &p, nil
+}
+
+func (p *rosterBaseService) EndService() {
+	p.CloseDatabaseService()
+	p.dbRegion.CloseDatabaseService()
+}
+
+// List - List All records
+func (p *rosterBaseService) List(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("RosterService::FindAll - Begin")
+
+	response, err := p.daoRoster.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("RosterService::FindAll - End ")
+	return response, nil
+}
+
+// Get - Get the roster entry
+func (p *rosterBaseService) Get(rosterId string) (utils.Map, error) {
+	log.Printf("RosterService::Get::  Begin %v", rosterId)
+
+	data, err := p.daoRoster.Get(rosterId)
+	log.Println("RosterService::Get:: End ", err)
+	return data, err
+}
+
+func (p *rosterBaseService) Find(filter string) (utils.Map, error) {
+	log.Println("RosterService::Find::  Begin ", filter)
+
+	data, err := p.daoRoster.Find(filter)
+	log.Println("RosterService::Find:: End ", data, err)
+	return data, err
+}
+
+// Delete - Delete Service
+func (p *rosterBaseService) Delete(rosterId string, delete_permanent bool) error {
+
+	log.Println("RosterService::Delete - Begin", rosterId)
+
+	_, err := p.daoRoster.Get(rosterId)
+	if err != nil {
+		return err
+	}
+
+	if delete_permanent {
+		result, err := p.daoRoster.Delete(rosterId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Delete %v", result)
+	} else {
+		indata := utils.Map{db_common.FLD_IS_DELETED: true}
+		data, err := p.daoRoster.Update(rosterId, indata)
+		if err != nil {
+			return err
+		}
+		log.Println("Update for Delete Flag", data)
+	}
+
+	log.Printf("RosterService::Delete - End")
+	return nil
+}
+
+// ****************************************************************
+// Generate - Generate the roster of the staff for each day between
+// from and to (YYYY-MM-DD) from the shift_pattern of the shift profile,
+// all the staffs having shift profile when staffId is empty. The days
+// overridden manually are kept as they are
+//
+// ****************************************************************
+func (p *rosterBaseService) Generate(staffId string, from string, to string) (utils.Map, error) {
+
+	log.Println("RosterService::Generate - Begin", staffId, from, to)
+
+	fromDate, toDate, err := parseRosterPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	staffs := []utils.Map{}
+	if len(staffId) > 0 {
+		staffData, err := p.daoStaff.Get(staffId)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+			return nil, err
+		}
+		staffs = append(staffs, staffData)
+	} else {
+		filter := fmt.Sprintf(`{"%s":{"$exists":true}}`, hr_common.FLD_SHIFT_PROFILE_ID)
+		response, err := p.daoStaff.List(filter, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		staffs, _ = response[db_common.LIST_RESULT].([]utils.Map)
+	}
+
+	generatedCount := 0
+	overrideCount := 0
+	skippedStaffs := []utils.Map{}
+	for _, staffData := range staffs {
+		staffId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_STAFF_ID)
+		skipStaff := func(reason string) {
+			skippedStaffs = append(skippedStaffs, utils.Map{hr_common.FLD_STAFF_ID: staffId, hr_common.FLD_REASON: reason})
+		}
+
+		shiftProfileId, pattern, patternStart, err := p.getStaffShiftPattern(staffData)
+		if err != nil {
+			skipStaff(err.Error())
+			continue
+		}
+
+		rosterDays, err := getRosterDays(p.daoRoster, staffId, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+			day := date.Format(time.DateOnly)
+			existing, dataOk := rosterDays[day]
+			if dataOk {
+				if isOverride, _ := utils.GetMemberDataBool(existing, hr_common.FLD_IS_OVERRIDE); isOverride {
+					overrideCount++
+					continue
+				}
+			}
+
+			shiftId := getPatternShiftId(pattern, patternStart, date)
+			rosterData := utils.Map{
+				hr_common.FLD_SHIFT_ID:         shiftId,
+				hr_common.FLD_SHIFT_PROFILE_ID: shiftProfileId,
+				hr_common.FLD_IS_OFF:           len(shiftId) == 0,
+				hr_common.FLD_IS_OVERRIDE:      false,
+			}
+			err = p.saveRosterDay(staffId, day, existing, rosterData)
+			if err != nil {
+				return nil, err
+			}
+			generatedCount++
+		}
+	}
+
+	log.Println("RosterService::Generate - End", generatedCount, overrideCount)
+	return utils.Map{
+		hr_common.FLD_GENERATED_COUNT: generatedCount,
+		hr_common.FLD_OVERRIDE_COUNT:  overrideCount,
+		hr_common.FLD_SKIPPED_STAFFS:  skippedStaffs,
+	}, nil
+}
+
+// ****************************************************************
+// Override - Assign the shift to the staff for a single day (YYYY-MM-DD),
+// shift_id is expected in indata, is_off is true for the off day
+//
+// ****************************************************************
+func (p *rosterBaseService) Override(staffId string, rosterDate string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("RosterService::Override - Begin", staffId, rosterDate)
+
+	_, err := time.Parse(time.DateOnly, rosterDate)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid roster_date", ErrorDetail: "roster_date should be in YYYY-MM-DD format"}
+		return nil, err
+	}
+	_, err = p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return nil, err
+	}
+
+	isOff, _ := utils.GetMemberDataBool(indata, hr_common.FLD_IS_OFF)
+	shiftId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_SHIFT_ID)
+	if isOff {
+		shiftId = ""
+	} else {
+		_, err = p.daoShift.Get(shiftId)
+		if err != nil {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid shift_id", ErrorDetail: "shift_id should be an existing shift unless is_off is true"}
+			return nil, err
+		}
+	}
+
+	rosterData := utils.Map{
+		hr_common.FLD_SHIFT_ID:    shiftId,
+		hr_common.FLD_IS_OFF:      isOff,
+		hr_common.FLD_IS_OVERRIDE: true,
+	}
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		rosterData[hr_common.FLD_REMARKS] = remarks
+	}
+
+	existing, _ := getRosterEntry(p.daoRoster, staffId, rosterDate)
+	err = p.saveRosterDay(staffId, rosterDate, existing, rosterData)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := getRosterEntry(p.daoRoster, staffId, rosterDate)
+	log.Println("RosterService::Override - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// ClearOverride - Assign back the shift from the shift_pattern for the
+// day (YYYY-MM-DD) overridden manually
+//
+// ****************************************************************
+func (p *rosterBaseService) ClearOverride(staffId string, rosterDate string) (utils.Map, error) {
+
+	log.Println("RosterService::ClearOverride - Begin", staffId, rosterDate)
+
+	existing, err := getRosterEntry(p.daoRoster, staffId, rosterDate)
+	if err != nil || existing == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid roster_date", ErrorDetail: "No roster found for the staff on the date"}
+		return nil, err
+	}
+	if isOverride, _ := utils.GetMemberDataBool(existing, hr_common.FLD_IS_OVERRIDE); !isOverride {
+		return existing, nil
+	}
+
+	staffData, err := p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return nil, err
+	}
+	shiftProfileId, pattern, patternStart, err := p.getStaffShiftPattern(staffData)
+	if err != nil {
+		return nil, err
+	}
+	date, _ := time.Parse(time.DateOnly, rosterDate)
+	shiftId := getPatternShiftId(pattern, patternStart, date)
+
+	rosterId, _ := utils.GetMemberDataStr(existing, hr_common.FLD_ROSTER_ID)
+	rosterData := utils.Map{
+		hr_common.FLD_SHIFT_ID:         shiftId,
+		hr_common.FLD_SHIFT_PROFILE_ID: shiftProfileId,
+		hr_common.FLD_IS_OFF:           len(shiftId) == 0,
+		hr_common.FLD_IS_OVERRIDE:      false,
+	}
+	_, err = p.daoRoster.Update(rosterId, rosterData)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.daoRoster.Get(rosterId)
+	log.Println("RosterService::ClearOverride - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// GetStaffRoster - Get the roster of the staff for each day between
+// from and to (YYYY-MM-DD), with the shift details
+//
+// ****************************************************************
+func (p *rosterBaseService) GetStaffRoster(staffId string, from string, to string) (utils.Map, error) {
+
+	log.Println("RosterService::GetStaffRoster - Begin", staffId, from, to)
+
+	fromDate, toDate, err := parseRosterPeriod(from, to)
+	if err != nil {
+		return nil, err
+	}
+	rosterDays, err := getRosterDays(p.daoRoster, staffId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	shifts := map[string]utils.Map{}
+	rosters := []utils.Map{}
+	for date := fromDate; !date.After(toDate); date = date.AddDate(0, 0, 1) {
+		rosterData, dataOk := rosterDays[date.Format(time.DateOnly)]
+		if !dataOk {
+			continue
+		}
+		if shiftId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID); len(shiftId) > 0 {
+			if _, dataOk := shifts[shiftId]; !dataOk {
+				shifts[shiftId], _ = p.daoShift.Get(shiftId)
+			}
+			rosterData[hr_common.FLD_SHIFT_INFO] = shifts[shiftId]
+		}
+		rosters = append(rosters, rosterData)
+	}
+
+	log.Println("RosterService::GetStaffRoster - End", len(rosters))
+	return utils.Map{
+		hr_common.FLD_STAFF_ID: staffId,
+		hr_common.FLD_ROSTERS:  rosters,
+	}, nil
+}
+
+func (p *rosterBaseService) errorReturn(err error) (RosterService, error) {
+	// Close the Database Connection
+	p.EndService()
+	return nil, err
+}
+
+// saveRosterDay - Update the existing roster entry of the staff on the day, otherwise create it
+func (p *rosterBaseService) saveRosterDay(staffId string, rosterDate string, existing utils.Map, rosterData utils.Map) error {
+
+	if existing != nil {
+		rosterId, _ := utils.GetMemberDataStr(existing, hr_common.FLD_ROSTER_ID)
+		_, err := p.daoRoster.Update(rosterId, rosterData)
+		return err
+	}
+
+	rosterData[hr_common.FLD_ROSTER_ID] = utils.GenerateUniqueId("rost")
+	rosterData[hr_common.FLD_BUSINESS_ID] = p.businessId
+	rosterData[hr_common.FLD_STAFF_ID] = staffId
+	rosterData[hr_common.FLD_ROSTER_DATE] = rosterDate
+	_, err := p.daoRoster.Create(rosterData)
+	return err
+}
+
+// getStaffShiftPattern - Get the shift profile of the staff, its shift_pattern and the date the pattern starts for the staff
+func (p *rosterBaseService) getStaffShiftPattern(staffData utils.Map) (string, []utils.Map, time.Time, error) {
+
+	shiftProfileId, _ := utils.GetMemberDataStr(staffData, hr_common.FLD_SHIFT_PROFILE_ID)
+	shiftProfileData, err := p.daoShiftProfile.Get(shiftProfileId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No Shift Profile", ErrorDetail: "No shift profile found for the staff"}
+		return "", nil, time.Time{}, err
+	}
+
+	steps, _ := hr_common.ToArray(shiftProfileData[hr_common.FLD_SHIFT_PATTERN])
+	pattern := []utils.Map{}
+	for _, step := range steps {
+		stepData, ok := hr_common.ToMap(step)
+		if !ok {
+			continue
+		}
+		if days, _ := utils.GetMemberDataInt(stepData, hr_common.FLD_DAYS, true); days > 0 {
+			pattern = append(pattern, stepData)
+		}
+	}
+	if len(pattern) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No shift_pattern", ErrorDetail: "Shift profile of the staff has no shift_pattern"}
+		return "", nil, time.Time{}, err
+	}
+
+	startDate, err := utils.GetMemberDataStr(staffData, hr_common.FLD_PATTERN_START_DATE)
+	if err != nil {
+		startDate, _ = utils.GetMemberDataStr(shiftProfileData, hr_common.FLD_PATTERN_START_DATE)
+	}
+	patternStart, err := time.Parse(time.DateOnly, startDate)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid pattern_start_date", ErrorDetail: "pattern_start_date of the staff or the shift profile should be in YYYY-MM-DD format"}
+		return "", nil, time.Time{}, err
+	}
+	return shiftProfileId, pattern, patternStart, nil
+}
+
+// parseRosterPeriod - Parse from and to dates (YYYY-MM-DD) of the roster within MAX_ROSTER_DAYS
+func parseRosterPeriod(from string, to string) (time.Time, time.Time, error) {
+
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid from", ErrorDetail: "from date should be in YYYY-MM-DD format"}
+		return time.Time{}, time.Time{}, err
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid to", ErrorDetail: "to date should be in YYYY-MM-DD format"}
+		return time.Time{}, time.Time{}, err
+	}
+	if toDate.Before(fromDate) || toDate.Sub(fromDate).Hours()/24 >= MAX_ROSTER_DAYS {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Date Range", ErrorDetail: fmt.Sprintf("to date should be after from date and within %v days", MAX_ROSTER_DAYS)}
+		return time.Time{}, time.Time{}, err
+	}
+	return fromDate, toDate, nil
+}
+
+// getPatternShiftId - Get the shift_id of the pattern repeated from patternStart on the date, empty for the off day
+func getPatternShiftId(pattern []utils.Map, patternStart time.Time, onDate time.Time) string {
+
+	cycleDays := 0
+	for _, step := range pattern {
+		days, _ := utils.GetMemberDataInt(step, hr_common.FLD_DAYS, true)
+		cycleDays += days
+	}
+	if cycleDays == 0 {
+		return ""
+	}
+
+	// Dates before the pattern start are in the previous cycles
+	offset := int(onDate.Sub(patternStart).Hours() / 24)
+	offset = ((offset % cycleDays) + cycleDays) % cycleDays
+	for _, step := range pattern {
+		days, _ := utils.GetMemberDataInt(step, hr_common.FLD_DAYS, true)
+		if offset < days {
+			shiftId, _ := utils.GetMemberDataStr(step, hr_common.FLD_SHIFT_ID)
+			return shiftId
+		}
+		offset -= days
+	}
+	return ""
+}
+
+// getRosterEntry - Get the roster entry of the staff on the date (YYYY-MM-DD), nil when not rostered
+func getRosterEntry(daoRoster hr_repository.RosterDao, staffId string, rosterDate string) (utils.Map, error) {
+
+	rosterDays, err := getRosterDays(daoRoster, staffId, rosterDate, rosterDate)
+	if err != nil {
+		return nil, err
+	}
+	return rosterDays[rosterDate], nil
+}
+
+// getRosterDays - Get the roster entries of the staff between from and to (YYYY-MM-DD) by the date
+func getRosterDays(daoRoster hr_repository.RosterDao, staffId string, from string, to string) (map[string]utils.Map, error) {
+
+	rosterDays := map[string]utils.Map{}
+	if daoRoster == nil {
+		return rosterDays, nil
+	}
+
+	filter, _ := json.Marshal(utils.Map{
+		hr_common.FLD_STAFF_ID:    staffId,
+		hr_common.FLD_ROSTER_DATE: utils.Map{"$gte": from, "$lte": to},
+	})
+	response, err := daoRoster.List(string(filter), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	rosters, _ := response[db_common.LIST_RESULT].([]utils.Map)
+	for _, rosterData := range rosters {
+		rosterDate, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_ROSTER_DATE)
+		rosterDays[rosterDate] = rosterData
+	}
+	return rosterDays, nil
+}
+
+// getRosterEntryForPunch - Get the roster entry of the staff the punch belongs to, the roster of the previous
+// day when the punch is within its rollover shift, nil when the staff is not rostered on the day
+func getRosterEntryForPunch(daoRoster hr_repository.RosterDao, daoShift hr_repository.ShiftDao, staffId string, punchTime time.Time) utils.Map {
+
+	prevDay := punchTime.AddDate(0, 0, -1)
+	rosterDays, err := getRosterDays(daoRoster, staffId, prevDay.Format(time.DateOnly), punchTime.Format(time.DateOnly))
+	if err != nil {
+		return nil
+	}
+
+	rosterData := rosterDays[punchTime.Format(time.DateOnly)]
+	prevData, dataOk := rosterDays[prevDay.Format(time.DateOnly)]
+	if !dataOk {
+		return rosterData
+	}
+	prevShiftId, _ := utils.GetMemberDataStr(prevData, hr_common.FLD_SHIFT_ID)
+	prevShift, err := daoShift.Get(prevShiftId)
+	if err != nil {
+		return rosterData
+	}
+	prevStart, prevEnd, err := getShiftWindow(prevShift, prevDay)
+	if err != nil || !prevEnd.After(punchTime) {
+		return rosterData
+	}
+
+	// Punch within the shift of the previous day, unless nearer to the shift of the day
+	shiftId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID)
+	if shiftData, err := daoShift.Get(shiftId); err == nil {
+		shiftStart, _, err := getShiftWindow(shiftData, punchTime)
+		if err == nil && absDuration(punchTime.Sub(shiftStart)) < absDuration(punchTime.Sub(prevStart)) {
+			return rosterData
+		}
+	}
+	return prevData
+}
+
+// isRosterOffDay - Whether the date is off for the staff as per the roster, the weekly offs
+// of the shift profile are applicable for the days not rostered
+func isRosterOffDay(rosterDays map[string]utils.Map, weeklyOffs map[time.Weekday]bool, date time.Time) bool {
+
+	if rosterData, dataOk := rosterDays[date.Format(time.DateOnly)]; dataOk {
+		isOff, _ := utils.GetMemberDataBool(rosterData, hr_common.FLD_IS_OFF)
+		return isOff
+	}
+	return weeklyOffs[date.Weekday()]
+}
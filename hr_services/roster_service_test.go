@@ -0,0 +1,46 @@
+package hr_services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+func TestGetPatternShiftId(t *testing.T) {
+
+	pattern := []utils.Map{
+		{hr_common.FLD_SHIFT_ID: "morning", hr_common.FLD_DAYS: 2},
+		{hr_common.FLD_SHIFT_ID: "night", hr_common.FLD_DAYS: 3},
+		{hr_common.FLD_SHIFT_ID: "", hr_common.FLD_DAYS: 2},
+	}
+	patternStart := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		pattern []utils.Map
+		onDate  string
+		want    string
+	}{
+		{name: "pattern start", pattern: pattern, onDate: "2025-03-10", want: "morning"},
+		{name: "last day of first step", pattern: pattern, onDate: "2025-03-11", want: "morning"},
+		{name: "second step", pattern: pattern, onDate: "2025-03-12", want: "night"},
+		{name: "off days", pattern: pattern, onDate: "2025-03-16", want: ""},
+		{name: "full cycle wraps to the start", pattern: pattern, onDate: "2025-03-17", want: "morning"},
+		{name: "later cycle", pattern: pattern, onDate: "2025-04-02", want: "night"},
+		{name: "day before the start is end of previous cycle", pattern: pattern, onDate: "2025-03-09", want: ""},
+		{name: "before the start in previous cycle", pattern: pattern, onDate: "2025-03-05", want: "night"},
+		{name: "full cycle before the start", pattern: pattern, onDate: "2025-03-03", want: "morning"},
+		{name: "empty pattern", pattern: []utils.Map{}, onDate: "2025-03-10", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			onDate, _ := time.Parse(time.DateOnly, test.onDate)
+			if shiftId := getPatternShiftId(test.pattern, patternStart, onDate); shiftId != test.want {
+				t.Errorf("shift_id = %q, want %q", shiftId, test.want)
+			}
+		})
+	}
+}
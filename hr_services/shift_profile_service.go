@@ -3,6 +3,7 @@ package hr_services
 import (
 	"log"
 	"strings"
+	"time"
 
 	"github.com/zapscloud/golib-dbutils/db_common"
 	"github.com/zapscloud/golib-dbutils/db_utils"
@@ -33,7 +34,8 @@ type ShiftProfileService interface {
 type shiftProfileBaseService struct {
 	db_utils.DatabaseService
 	dbRegion            db_utils.DatabaseService
-	daoShift            hr_repository.ShiftProfileDao
+	daoShiftProfile     hr_repository.ShiftProfileDao
+	daoShift            hr_repository.ShiftDao
 	daoPlatformBusiness platform_repository.BusinessDao
 
 	child      ShiftProfileService
@@ -74,7 +76,8 @@ func NewShiftProfileService(props utils.Map) (ShiftProfileService, error) {
 	p.businessId = businessId
 
 	// Instantiate other services
-	p.daoShift = hr_repository.NewShiftProfileDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShiftProfile = hr_repository.NewShiftProfileDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
 	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
 
 	_, err = p.daoPlatformBusiness.Get(p.businessId)
@@ -101,8 +104,8 @@ func (p *shiftProfileBaseService) List(filter string, sort string, skip int64, l
 
 	log.Println("ShiftProfileService::FindAll - Begin")
 
-	daoShift := p.daoShift
-	response, err := daoShift.List(filter, sort, skip, limit)
+	daoShiftProfile := p.daoShiftProfile
+	response, err := daoShiftProfile.List(filter, sort, skip, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +118,7 @@ func (p *shiftProfileBaseService) List(filter string, sort string, skip int64, l
 func (p *shiftProfileBaseService) Get(shiftProfileId string) (utils.Map, error) {
 	log.Printf("ShiftProfileService::FindByCode::  Begin %v", shiftProfileId)
 
-	data, err := p.daoShift.Get(shiftProfileId)
+	data, err := p.daoShiftProfile.Get(shiftProfileId)
 	log.Println("ShiftProfileService::FindByCode:: End ", err)
 	return data, err
 }
@@ -123,7 +126,7 @@ func (p *shiftProfileBaseService) Get(shiftProfileId string) (utils.Map, error)
 func (p *shiftProfileBaseService) Find(filter string) (utils.Map, error) {
 	log.Println("ShiftProfileService::FindByCode::  Begin ", filter)
 
-	data, err := p.daoShift.Find(filter)
+	data, err := p.daoShiftProfile.Find(filter)
 	log.Println("ShiftProfileService::FindByCode:: End ", data, err)
 	return data, err
 }
@@ -145,7 +148,7 @@ func (p *shiftProfileBaseService) Create(indata utils.Map) (utils.Map, error) {
 	indata[hr_common.FLD_BUSINESS_ID] = p.businessId
 	log.Println("Provided Account ID:", shiftProfileId)
 
-	_, err := p.daoShift.Get(shiftProfileId)
+	_, err := p.daoShiftProfile.Get(shiftProfileId)
 	if err == nil {
 		err := &utils.AppError{
 			ErrorCode:   "S30102",
@@ -158,7 +161,17 @@ func (p *shiftProfileBaseService) Create(indata utils.Map) (utils.Map, error) {
 	// 	return indata, err
 	// }
 
-	insertResult, err := p.daoShift.Create(indata)
+	err = p.validateWeeklyOffs(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	err = p.validateShiftPattern(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	insertResult, err := p.daoShiftProfile.Create(indata)
 	if err != nil {
 		return indata, err
 	}
@@ -171,7 +184,7 @@ func (p *shiftProfileBaseService) Update(shiftProfileId string, indata utils.Map
 
 	log.Println("ShiftProfileService::Update - Begin")
 
-	data, err := p.daoShift.Get(shiftProfileId)
+	data, err := p.daoShiftProfile.Get(shiftProfileId)
 	if err != nil {
 		return data, err
 	}
@@ -186,7 +199,17 @@ func (p *shiftProfileBaseService) Update(shiftProfileId string, indata utils.Map
 	// 	return indata, err
 	// }
 
-	data, err = p.daoShift.Update(shiftProfileId, indata)
+	err = p.validateWeeklyOffs(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	err = p.validateShiftPattern(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	data, err = p.daoShiftProfile.Update(shiftProfileId, indata)
 	log.Println("ShiftProfileService::Update - End ", err)
 	return data, err
 }
@@ -196,9 +219,9 @@ func (p *shiftProfileBaseService) Delete(shiftProfileId string, delete_permanent
 
 	log.Println("ShiftProfileService::Delete - Begin", shiftProfileId)
 
-	daoShift := p.daoShift
+	daoShiftProfile := p.daoShiftProfile
 	if delete_permanent {
-		result, err := daoShift.Delete(shiftProfileId)
+		result, err := daoShiftProfile.Delete(shiftProfileId)
 		if err != nil {
 			return err
 		}
@@ -222,6 +245,82 @@ func (p *shiftProfileBaseService) errorReturn(err error) (ShiftProfileService, e
 	return nil, err
 }
 
+// validateWeeklyOffs - Verify the weekly_offs are valid weekday names
+func (p *shiftProfileBaseService) validateWeeklyOffs(indata utils.Map) error {
+
+	dataVal, dataOk := indata[hr_common.FLD_WEEKLY_OFFS]
+	if !dataOk {
+		return nil
+	}
+
+	errWeeklyOffs := &utils.AppError{
+		ErrorCode:   "S30102",
+		ErrorMsg:    "Invalid weekly_offs",
+		ErrorDetail: "weekly_offs should be an array of weekday names"}
+
+	days, ok := hr_common.ToArray(dataVal)
+	if !ok {
+		return errWeeklyOffs
+	}
+	for _, day := range days {
+		if _, ok := hr_common.ParseWeekday(day); !ok {
+			return errWeeklyOffs
+		}
+	}
+	return nil
+}
+
+// validateShiftPattern - Verify the shift_pattern refers the existing shifts with positive days
+func (p *shiftProfileBaseService) validateShiftPattern(indata utils.Map) error {
+
+	if dataVal, dataOk := indata[hr_common.FLD_PATTERN_START_DATE]; dataOk {
+		startDate, _ := dataVal.(string)
+		if _, err := time.Parse(time.DateOnly, startDate); err != nil {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid pattern_start_date",
+				ErrorDetail: "pattern_start_date should be in YYYY-MM-DD format"}
+			return err
+		}
+	}
+
+	dataVal, dataOk := indata[hr_common.FLD_SHIFT_PATTERN]
+	if !dataOk {
+		return nil
+	}
+
+	errShiftPattern := &utils.AppError{
+		ErrorCode:   "S30102",
+		ErrorMsg:    "Invalid shift_pattern",
+		ErrorDetail: "shift_pattern should be an array of shift_id with days, shift_id is empty for the off days"}
+
+	steps, ok := hr_common.ToArray(dataVal)
+	if !ok || len(steps) == 0 {
+		return errShiftPattern
+	}
+	for _, step := range steps {
+		stepData, ok := hr_common.ToMap(step)
+		if !ok {
+			return errShiftPattern
+		}
+		days, err := utils.GetMemberDataInt(stepData, hr_common.FLD_DAYS, true)
+		if err != nil || days <= 0 {
+			return errShiftPattern
+		}
+		if shiftId, _ := utils.GetMemberDataStr(stepData, hr_common.FLD_SHIFT_ID); len(shiftId) > 0 {
+			_, err := p.daoShift.Get(shiftId)
+			if err != nil {
+				err := &utils.AppError{
+					ErrorCode:   "S30102",
+					ErrorMsg:    "Invalid shift_pattern",
+					ErrorDetail: "Shift " + shiftId + " in the shift_pattern is not exist"}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // func (p *shiftProfileBaseService) validateTimeFormat(indata utils.Map) error {
 // 	// Convert Time string to Date Format
 // 	shiftFromTime, err := utils.GetMemberDataStr(indata, hr_common.FLD_SHIFT_FROM)
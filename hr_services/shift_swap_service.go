@@ -0,0 +1,671 @@
+package hr_services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zapscloud/golib-dbutils/db_utils"
+	"github.com/zapscloud/golib-hr/hr_common"
+	"github.com/zapscloud/golib-hr/hr_repository"
+	"github.com/zapscloud/golib-platform/platform_repository"
+	"github.com/zapscloud/golib-platform/platform_services"
+	"github.com/zapscloud/golib-utils/utils"
+)
+
+// ShiftSwapService - Shift Swap Service structure
+type ShiftSwapService interface {
+	List(filter string, sort string, skip int64, limit int64) (utils.Map, error)
+	Get(shiftSwapId string) (utils.Map, error)
+	Find(filter string) (utils.Map, error)
+	Request(indata utils.Map) (utils.Map, error)
+	Accept(shiftSwapId string, indata utils.Map) (utils.Map, error)
+	Decline(shiftSwapId string, indata utils.Map) (utils.Map, error)
+	Approve(shiftSwapId string, indata utils.Map) (utils.Map, error)
+	Reject(shiftSwapId string, indata utils.Map) (utils.Map, error)
+	Cancel(shiftSwapId string, indata utils.Map) (utils.Map, error)
+
+	BeginTransaction()
+	CommitTransaction()
+	RollbackTransaction()
+
+	EndService()
+}
+
+// shiftSwapBaseService - Shift Swap Service structure
+type shiftSwapBaseService struct {
+	db_utils.DatabaseService
+	dbRegion            db_utils.DatabaseService
+	daoShiftSwap        hr_repository.ShiftSwapDao
+	daoRoster           hr_repository.RosterDao
+	daoShift            hr_repository.ShiftDao
+	daoStaff            hr_repository.StaffDao
+	daoPosition         hr_repository.PositionDao
+	daoPlatformBusiness platform_repository.BusinessDao
+
+	child      ShiftSwapService
+	businessId string
+	staffId    string
+
+	minRestHours         int
+	maxConsecutiveShifts int
+}
+
+const (
+	DEFAULT_MIN_REST_HOURS         = 11
+	DEFAULT_MAX_CONSECUTIVE_SHIFTS = 6
+)
+
+// Allowed changes of the swap status
+var swapStatusTransitions = map[string][]string{
+	hr_common.SWAP_STATUS_REQUESTED: {hr_common.SWAP_STATUS_ACCEPTED, hr_common.SWAP_STATUS_DECLINED, hr_common.SWAP_STATUS_CANCELLED},
+	hr_common.SWAP_STATUS_ACCEPTED:  {hr_common.SWAP_STATUS_APPROVED, hr_common.SWAP_STATUS_REJECTED, hr_common.SWAP_STATUS_CANCELLED},
+}
+
+func init() {
+	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
+}
+
+func NewShiftSwapService(props utils.Map) (ShiftSwapService, error) {
+	funcode := hr_common.GetServiceModuleCode() + "M" + "01"
+
+	log.Printf("ShiftSwapService::Start")
+
+	// Verify whether the business id data passed
+	businessId, err := utils.GetMemberDataStr(props, hr_common.FLD_BUSINESS_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := shiftSwapBaseService{}
+
+	// Open Database Service
+	err = p.OpenDatabaseService(props)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open RegionDB Service
+	p.dbRegion, err = platform_services.OpenRegionDatabaseService(props)
+	if err != nil {
+		p.CloseDatabaseService()
+		return nil, err
+	}
+
+	// Verify whether the User id data passed, this is optional parameter
+	staffId, _ := utils.GetMemberDataStr(props, hr_common.FLD_STAFF_ID)
+
+	// Assign the BusinessId & StaffId
+	p.businessId = businessId
+	p.staffId = staffId
+
+	// Instantiate other services, swaps are shared between the requester and the counterpart
+	p.daoPlatformBusiness = platform_repository.NewBusinessDao(p.GetClient())
+	p.daoShiftSwap = hr_repository.NewShiftSwapDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoRoster = hr_repository.NewRosterDao(p.dbRegion.GetClient(), p.businessId, "")
+	p.daoShift = hr_repository.NewShiftDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoStaff = hr_repository.NewStaffDao(p.dbRegion.GetClient(), p.businessId)
+	p.daoPosition = hr_repository.NewPositionDao(p.dbRegion.GetClient(), p.businessId)
+
+	// Rest period & consecutive shift rules, these are optional parameters
+	p.minRestHours, err = utils.GetMemberDataInt(props, hr_common.FLD_MIN_REST_HOURS, true)
+	if err != nil || p.minRestHours < 0 {
+		p.minRestHours = DEFAULT_MIN_REST_HOURS
+	}
+	p.maxConsecutiveShifts, err = utils.GetMemberDataInt(props, hr_common.FLD_MAX_CONSECUTIVE_SHIFTS, true)
+	if err != nil || p.maxConsecutiveShifts <= 0 {
+		p.maxConsecutiveShifts = DEFAULT_MAX_CONSECUTIVE_SHIFTS
+	}
+
+	_, err = p.daoPlatformBusiness.Get(p.businessId)
+	if err != nil {
+		err := &utils.AppError{
+			ErrorCode:   funcode + "01",
+			ErrorMsg:    "Invalid business id",
+			ErrorDetail: "Given business id is not exist"}
+		return p.errorReturn(err)
+	}
+
+	// Verify the Staff Exist
+	if len(staffId) > 0 {
+		_, err = p.daoStaff.Get(staffId)
+		if err != nil {
+			err := &utils.AppError{
+				ErrorCode:   funcode + "01",
+				ErrorMsg:    "Invalid StaffId",
+				ErrorDetail: "Given StaffId is not exist"}
+			return p.errorReturn(err)
+		}
+	}
+
+	p.child = &p
+
+	return &p, nil
+}
+
+func (p *shiftSwapBaseService) EndService() {
+	p.CloseDatabaseService()
+	p.dbRegion.CloseDatabaseService()
+}
+
+// List - List All records
+func (p *shiftSwapBaseService) List(filter string, sort string, skip int64, limit int64) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::FindAll - Begin")
+
+	response, err := p.daoShiftSwap.List(filter, sort, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("ShiftSwapService::FindAll - End ")
+	return response, nil
+}
+
+// Get - Get the swap request
+func (p *shiftSwapBaseService) Get(shiftSwapId string) (utils.Map, error) {
+	log.Printf("ShiftSwapService::Get::  Begin %v", shiftSwapId)
+
+	data, err := p.daoShiftSwap.Get(shiftSwapId)
+	log.Println("ShiftSwapService::Get:: End ", err)
+	return data, err
+}
+
+func (p *shiftSwapBaseService) Find(filter string) (utils.Map, error) {
+	log.Println("ShiftSwapService::Find::  Begin ", filter)
+
+	data, err := p.daoShiftSwap.Find(filter)
+	log.Println("ShiftSwapService::Find:: End ", data, err)
+	return data, err
+}
+
+// ****************************************************************
+// Request - Request the counterpart_staff_id to trade the shifts
+// rostered on the roster_date, the swap is approved by the manager
+// once the counterpart accepted it
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Request(indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Request - Begin")
+
+	var shiftSwapId string
+
+	dataval, dataok := indata[hr_common.FLD_SHIFT_SWAP_ID]
+	if dataok {
+		shiftSwapId = strings.ToLower(dataval.(string))
+	} else {
+		shiftSwapId = utils.GenerateUniqueId("swap")
+		log.Println("Unique ShiftSwap ID", shiftSwapId)
+	}
+
+	_, err := p.daoShiftSwap.Get(shiftSwapId)
+	if err == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing ShiftSwap ID !", ErrorDetail: "Given ShiftSwap ID already exist"}
+		return utils.Map{}, err
+	}
+
+	// StaffId from the service takes precedence over the one sent in indata
+	staffId := p.staffId
+	if len(staffId) == 0 {
+		staffId, _ = utils.GetMemberDataStr(indata, hr_common.FLD_STAFF_ID)
+	}
+	_, err = p.daoStaff.Get(staffId)
+	if err != nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid StaffId", ErrorDetail: "No such StaffId found"}
+		return utils.Map{}, err
+	}
+	counterpartId, _ := utils.GetMemberDataStr(indata, hr_common.FLD_COUNTERPART_STAFF_ID)
+	_, err = p.daoStaff.Get(counterpartId)
+	if err != nil || counterpartId == staffId {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid counterpart_staff_id", ErrorDetail: "counterpart_staff_id should be an existing staff other than the requester"}
+		return utils.Map{}, err
+	}
+
+	rosterDate, _ := utils.GetMemberDataStr(indata, hr_common.FLD_ROSTER_DATE)
+	_, err = time.Parse(time.DateOnly, rosterDate)
+	if err != nil || rosterDate < time.Now().Format(time.DateOnly) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid roster_date", ErrorDetail: "roster_date should be today or later in YYYY-MM-DD format"}
+		return utils.Map{}, err
+	}
+
+	rosterData, _ := getRosterEntry(p.daoRoster, staffId, rosterDate)
+	counterpartRoster, _ := getRosterEntry(p.daoRoster, counterpartId, rosterDate)
+	if rosterData == nil || counterpartRoster == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not Rostered", ErrorDetail: "Both the staffs should be rostered on the roster_date"}
+		return utils.Map{}, err
+	}
+	shiftId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID)
+	counterpartShiftId, _ := utils.GetMemberDataStr(counterpartRoster, hr_common.FLD_SHIFT_ID)
+	if shiftId == counterpartShiftId {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Same Shift", ErrorDetail: "Both the staffs are rostered on the same shift"}
+		return utils.Map{}, err
+	}
+
+	rosterId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_ROSTER_ID)
+	counterpartRosterId, _ := utils.GetMemberDataStr(counterpartRoster, hr_common.FLD_ROSTER_ID)
+	err = p.validateOpenSwaps([]string{rosterId, counterpartRosterId})
+	if err != nil {
+		return utils.Map{}, err
+	}
+
+	swapData := utils.Map{
+		hr_common.FLD_SHIFT_SWAP_ID:         shiftSwapId,
+		hr_common.FLD_BUSINESS_ID:           p.businessId,
+		hr_common.FLD_STAFF_ID:              staffId,
+		hr_common.FLD_COUNTERPART_STAFF_ID:  counterpartId,
+		hr_common.FLD_ROSTER_DATE:           rosterDate,
+		hr_common.FLD_ROSTER_ID:             rosterId,
+		hr_common.FLD_SHIFT_ID:              shiftId,
+		hr_common.FLD_COUNTERPART_ROSTER_ID: counterpartRosterId,
+		hr_common.FLD_COUNTERPART_SHIFT_ID:  counterpartShiftId,
+		hr_common.FLD_SWAP_STATUS:           hr_common.SWAP_STATUS_REQUESTED,
+	}
+	swapData[hr_common.FLD_SWAP_HISTORY] = appendSwapHistory(swapData, hr_common.SWAP_STATUS_REQUESTED, staffId, indata)
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		swapData[hr_common.FLD_REMARKS] = remarks
+	}
+
+	insertResult, err := p.daoShiftSwap.Create(swapData)
+	if err != nil {
+		return utils.Map{}, err
+	}
+	log.Println("ShiftSwapService::Request - End ", insertResult)
+	return swapData, nil
+}
+
+// ****************************************************************
+// Accept - Accept the swap by the counterpart, the rest period and
+// the consecutive shifts of both the staffs are verified after the
+// swap. acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Accept(shiftSwapId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Accept - Begin", shiftSwapId)
+
+	data, err := p.getSwapForAction(shiftSwapId, hr_common.SWAP_STATUS_ACCEPTED, indata, hr_common.FLD_COUNTERPART_STAFF_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.validateSwap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = p.updateSwapStatus(shiftSwapId, data, hr_common.SWAP_STATUS_ACCEPTED, indata)
+
+	log.Println("ShiftSwapService::Accept - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Decline - Decline the swap by the counterpart. acted_by and
+// optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Decline(shiftSwapId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Decline - Begin", shiftSwapId)
+
+	data, err := p.getSwapForAction(shiftSwapId, hr_common.SWAP_STATUS_DECLINED, indata, hr_common.FLD_COUNTERPART_STAFF_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = p.updateSwapStatus(shiftSwapId, data, hr_common.SWAP_STATUS_DECLINED, indata)
+
+	log.Println("ShiftSwapService::Decline - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Approve - Approve the accepted swap by the manager, the roster
+// entries of both the staffs are swapped in a transaction. acted_by
+// and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Approve(shiftSwapId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Approve - Begin", shiftSwapId)
+
+	data, err := p.getSwapForAction(shiftSwapId, hr_common.SWAP_STATUS_APPROVED, indata, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.validateSwapApprover(data, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rosters might have changed since the swap was accepted
+	err = p.validateSwap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both the roster entries are swapped along with the status, or none of them
+	p.dbRegion.BeginTransaction()
+	data, err = p.applySwap(shiftSwapId, data, indata)
+	if err != nil {
+		p.dbRegion.RollbackTransaction()
+		return nil, err
+	}
+	p.dbRegion.CommitTransaction()
+
+	log.Println("ShiftSwapService::Approve - End")
+	return data, nil
+}
+
+// ****************************************************************
+// Reject - Reject the accepted swap by the manager. acted_by and
+// optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Reject(shiftSwapId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Reject - Begin", shiftSwapId)
+
+	data, err := p.getSwapForAction(shiftSwapId, hr_common.SWAP_STATUS_REJECTED, indata, "")
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.validateSwapApprover(data, indata)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = p.updateSwapStatus(shiftSwapId, data, hr_common.SWAP_STATUS_REJECTED, indata)
+
+	log.Println("ShiftSwapService::Reject - End", err)
+	return data, err
+}
+
+// ****************************************************************
+// Cancel - Cancel the swap by the requester before the approval.
+// acted_by and optional remarks are expected in indata
+//
+// ****************************************************************
+func (p *shiftSwapBaseService) Cancel(shiftSwapId string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("ShiftSwapService::Cancel - Begin", shiftSwapId)
+
+	data, err := p.getSwapForAction(shiftSwapId, hr_common.SWAP_STATUS_CANCELLED, indata, hr_common.FLD_STAFF_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = p.updateSwapStatus(shiftSwapId, data, hr_common.SWAP_STATUS_CANCELLED, indata)
+
+	log.Println("ShiftSwapService::Cancel - End", err)
+	return data, err
+}
+
+func (p *shiftSwapBaseService) errorReturn(err error) (ShiftSwapService, error) {
+	// Close the Database Connection
+	p.EndService()
+	return nil, err
+}
+
+// getSwapForAction - Get the swap which can be moved to the status, acted_by should be the staff
+// in the actorField of the swap when given
+func (p *shiftSwapBaseService) getSwapForAction(shiftSwapId string, toStatus string, indata utils.Map, actorField string) (utils.Map, error) {
+
+	data, err := p.daoShiftSwap.Get(shiftSwapId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateSwapTransition(getSwapStatus(data), toStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	actedBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	if err != nil || len(actedBy) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No acted_by", ErrorDetail: "Staff id should be sent in acted_by"}
+		return nil, err
+	}
+	if len(actorField) > 0 {
+		if actorId, _ := utils.GetMemberDataStr(data, actorField); actorId != actedBy {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not Allowed", ErrorDetail: "Swap can be " + toStatus + " by " + actorId + " only"}
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// updateSwapStatus - Move the swap to the status with the action added to the history
+func (p *shiftSwapBaseService) updateSwapStatus(shiftSwapId string, data utils.Map, status string, indata utils.Map) (utils.Map, error) {
+
+	actedBy, _ := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+	updateData := utils.Map{
+		hr_common.FLD_SWAP_STATUS:  status,
+		hr_common.FLD_SWAP_HISTORY: appendSwapHistory(data, status, actedBy, indata),
+	}
+	return p.daoShiftSwap.Update(shiftSwapId, updateData)
+}
+
+// applySwap - Swap the shifts of the roster entries of both the staffs and approve the swap
+func (p *shiftSwapBaseService) applySwap(shiftSwapId string, data utils.Map, indata utils.Map) (utils.Map, error) {
+
+	shiftId, _ := utils.GetMemberDataStr(data, hr_common.FLD_SHIFT_ID)
+	counterpartShiftId, _ := utils.GetMemberDataStr(data, hr_common.FLD_COUNTERPART_SHIFT_ID)
+	rosterId, _ := utils.GetMemberDataStr(data, hr_common.FLD_ROSTER_ID)
+	counterpartRosterId, _ := utils.GetMemberDataStr(data, hr_common.FLD_COUNTERPART_ROSTER_ID)
+
+	for rosterId, newShiftId := range map[string]string{rosterId: counterpartShiftId, counterpartRosterId: shiftId} {
+		rosterData := utils.Map{
+			hr_common.FLD_SHIFT_ID:      newShiftId,
+			hr_common.FLD_IS_OFF:        len(newShiftId) == 0,
+			hr_common.FLD_IS_OVERRIDE:   true,
+			hr_common.FLD_SHIFT_SWAP_ID: shiftSwapId,
+		}
+		_, err := p.daoRoster.Update(rosterId, rosterData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.updateSwapStatus(shiftSwapId, data, hr_common.SWAP_STATUS_APPROVED, indata)
+}
+
+// validateOpenSwaps - Verify the roster entries are not part of the other swaps in progress
+func (p *shiftSwapBaseService) validateOpenSwaps(rosterIds []string) error {
+
+	filter, _ := json.Marshal(utils.Map{
+		"$or": []utils.Map{
+			{hr_common.FLD_ROSTER_ID: utils.Map{"$in": rosterIds}},
+			{hr_common.FLD_COUNTERPART_ROSTER_ID: utils.Map{"$in": rosterIds}},
+		},
+		hr_common.FLD_SWAP_STATUS: utils.Map{"$in": []string{hr_common.SWAP_STATUS_REQUESTED, hr_common.SWAP_STATUS_ACCEPTED}},
+	})
+	_, err := p.daoShiftSwap.Find(string(filter))
+	if err == nil {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Swap In Progress", ErrorDetail: "Shift of the staff is already part of another swap request"}
+		return err
+	}
+	return nil
+}
+
+// validateSwap - Verify the roster entries are not changed since requested, and the staffs
+// stay within the rest period & consecutive shift rules after the swap
+func (p *shiftSwapBaseService) validateSwap(data utils.Map) error {
+
+	rosterDate, _ := utils.GetMemberDataStr(data, hr_common.FLD_ROSTER_DATE)
+	if rosterDate < time.Now().Format(time.DateOnly) {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Roster Passed", ErrorDetail: "Shifts of the past days can not be swapped"}
+		return err
+	}
+
+	swapSides := []struct{ staffField, rosterField, shiftField, newShiftField string }{
+		{hr_common.FLD_STAFF_ID, hr_common.FLD_ROSTER_ID, hr_common.FLD_SHIFT_ID, hr_common.FLD_COUNTERPART_SHIFT_ID},
+		{hr_common.FLD_COUNTERPART_STAFF_ID, hr_common.FLD_COUNTERPART_ROSTER_ID, hr_common.FLD_COUNTERPART_SHIFT_ID, hr_common.FLD_SHIFT_ID},
+	}
+	for _, side := range swapSides {
+		rosterId, _ := utils.GetMemberDataStr(data, side.rosterField)
+		rosterData, err := p.daoRoster.Get(rosterId)
+		if err != nil {
+			return err
+		}
+		shiftId, _ := utils.GetMemberDataStr(data, side.shiftField)
+		if rosteredShiftId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID); rosteredShiftId != shiftId {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Roster Changed", ErrorDetail: "Roster of the staff is changed since the swap was requested"}
+			return err
+		}
+
+		staffId, _ := utils.GetMemberDataStr(data, side.staffField)
+		newShiftId, _ := utils.GetMemberDataStr(data, side.newShiftField)
+		err = p.validateSwapRules(staffId, rosterDate, newShiftId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSwapRules - Verify the staff gets min_rest_hours between the shifts and does not work more than
+// max_consecutive_shifts days in a row, when the shift is rostered on the date (YYYY-MM-DD)
+func (p *shiftSwapBaseService) validateSwapRules(staffId string, rosterDate string, shiftId string) error {
+
+	if len(shiftId) == 0 {
+		// Off day after the swap
+		return nil
+	}
+
+	date, _ := time.Parse(time.DateOnly, rosterDate)
+	rosterDays, err := getRosterDays(p.daoRoster, staffId,
+		date.AddDate(0, 0, -p.maxConsecutiveShifts).Format(time.DateOnly), date.AddDate(0, 0, p.maxConsecutiveShifts).Format(time.DateOnly))
+	if err != nil {
+		return err
+	}
+
+	shifts := map[string]utils.Map{}
+	getDayShift := func(onDate time.Time) utils.Map {
+		rosterData, dataOk := rosterDays[onDate.Format(time.DateOnly)]
+		if !dataOk {
+			return nil
+		}
+		dayShiftId, _ := utils.GetMemberDataStr(rosterData, hr_common.FLD_SHIFT_ID)
+		if len(dayShiftId) == 0 {
+			return nil
+		}
+		if _, dataOk := shifts[dayShiftId]; !dataOk {
+			shifts[dayShiftId], _ = p.daoShift.Get(dayShiftId)
+		}
+		return shifts[dayShiftId]
+	}
+
+	shiftData, err := p.daoShift.Get(shiftId)
+	if err != nil {
+		return err
+	}
+	shiftStart, shiftEnd, err := getShiftWindow(shiftData, date)
+	if err != nil {
+		return err
+	}
+
+	// Rest period from the shift of the previous day and till the shift of the next day
+	minRest := time.Duration(p.minRestHours) * time.Hour
+	errRest := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Insufficient Rest",
+		ErrorDetail: fmt.Sprintf("Staff %s should get %v hours of rest between the shifts", staffId, p.minRestHours)}
+	if prevShift := getDayShift(date.AddDate(0, 0, -1)); prevShift != nil {
+		_, prevEnd, err := getShiftWindow(prevShift, date.AddDate(0, 0, -1))
+		if err == nil && shiftStart.Sub(prevEnd) < minRest {
+			return errRest
+		}
+	}
+	if nextShift := getDayShift(date.AddDate(0, 0, 1)); nextShift != nil {
+		nextStart, _, err := getShiftWindow(nextShift, date.AddDate(0, 0, 1))
+		if err == nil && nextStart.Sub(shiftEnd) < minRest {
+			return errRest
+		}
+	}
+
+	// Working days in a row including the day
+	consecutiveShifts := 1
+	for day := date.AddDate(0, 0, -1); getDayShift(day) != nil; day = day.AddDate(0, 0, -1) {
+		consecutiveShifts++
+	}
+	for day := date.AddDate(0, 0, 1); getDayShift(day) != nil; day = day.AddDate(0, 0, 1) {
+		consecutiveShifts++
+	}
+	if consecutiveShifts > p.maxConsecutiveShifts {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Too Many Consecutive Shifts",
+			ErrorDetail: fmt.Sprintf("Staff %s should not work more than %v shifts in a row", staffId, p.maxConsecutiveShifts)}
+		return err
+	}
+	return nil
+}
+
+// validateSwapApprover - Approver should be the reporting manager of either staff, any staff other than
+// the requester and the counterpart when no reporting manager is found
+func (p *shiftSwapBaseService) validateSwapApprover(data utils.Map, indata utils.Map) error {
+
+	actedBy, _ := utils.GetMemberDataStr(indata, hr_common.FLD_ACTED_BY)
+
+	managers := []string{}
+	for _, key := range []string{hr_common.FLD_STAFF_ID, hr_common.FLD_COUNTERPART_STAFF_ID} {
+		staffId, _ := utils.GetMemberDataStr(data, key)
+		if staffId == actedBy {
+			err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Swap can not be approved by the staffs swapping the shifts"}
+			return err
+		}
+		staffData, err := p.daoStaff.Get(staffId)
+		if err != nil {
+			continue
+		}
+		if managerId := getReportingManager(p.daoStaff, p.daoPosition, staffData); len(managerId) > 0 {
+			if managerId == actedBy {
+				return nil
+			}
+			managers = append(managers, managerId)
+		}
+	}
+
+	if len(managers) > 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Not the Approver", ErrorDetail: "Swap is awaiting the action of " + strings.Join(managers, " or ")}
+		return err
+	}
+	return nil
+}
+
+// getSwapStatus - Status of the swap
+func getSwapStatus(swapData utils.Map) string {
+
+	status, _ := utils.GetMemberDataStr(swapData, hr_common.FLD_SWAP_STATUS)
+	return status
+}
+
+// validateSwapTransition - Verify the swap can be moved from the status to the other
+func validateSwapTransition(fromStatus string, toStatus string) error {
+
+	for _, status := range swapStatusTransitions[fromStatus] {
+		if status == toStatus {
+			return nil
+		}
+	}
+	err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Status", ErrorDetail: "Swap can not be " + toStatus + " when it is " + fromStatus}
+	return err
+}
+
+// appendSwapHistory - History of the swap with the new action added
+func appendSwapHistory(swapData utils.Map, action string, actedBy string, indata utils.Map) []any {
+
+	history, _ := hr_common.ToArray(swapData[hr_common.FLD_SWAP_HISTORY])
+
+	entry := utils.Map{
+		hr_common.FLD_ACTION:   action,
+		hr_common.FLD_ACTED_BY: actedBy,
+		hr_common.FLD_ACTED_AT: time.Now(),
+	}
+	if remarks, err := utils.GetMemberDataStr(indata, hr_common.FLD_REMARKS); err == nil {
+		entry[hr_common.FLD_REMARKS] = remarks
+	}
+	return append(history, entry)
+}
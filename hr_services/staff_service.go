@@ -2,6 +2,7 @@ package hr_services
 
 import (
 	"log"
+	"time"
 
 	"github.com/zapscloud/golib-dbutils/db_common"
 	"github.com/zapscloud/golib-dbutils/db_utils"
@@ -21,6 +22,9 @@ type StaffService interface {
 	Create(indata utils.Map) (utils.Map, error)
 	Update(staff_id string, indata utils.Map) (utils.Map, error)
 	Delete(staff_id string, delete_permanent bool) error
+	RegisterDevice(staff_id string, indata utils.Map) (utils.Map, error)
+	RevokeDevice(staff_id string, device_fingerprint string) (utils.Map, error)
+	ListDevices(staff_id string) (utils.Map, error)
 
 	BeginTransaction()
 	CommitTransaction()
@@ -146,6 +150,9 @@ func (p *staffBaseService) Create(indata utils.Map) (utils.Map, error) {
 	indata[hr_common.FLD_BUSINESS_ID] = p.businessID
 	log.Println("Provided Account ID:", dataval)
 
+	// Devices are managed with RegisterDevice / RevokeDevice only
+	delete(indata, hr_common.FLD_REGISTERED_DEVICES)
+
 	_, err := p.daoStaff.Get(dataval.(string))
 	if err == nil {
 		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing Account ID !", ErrorDetail: "Given Account ID already exist"}
@@ -170,6 +177,9 @@ func (p *staffBaseService) Update(staff_id string, indata utils.Map) (utils.Map,
 		return data, err
 	}
 
+	// Devices are managed with RegisterDevice / RevokeDevice only
+	delete(indata, hr_common.FLD_REGISTERED_DEVICES)
+
 	data, err = p.daoStaff.Update(staff_id, indata)
 	log.Println("AccountService::Update - End ")
 	return data, err
@@ -205,6 +215,109 @@ func (p *staffBaseService) Delete(staff_id string, delete_permanent bool) error
 	return nil
 }
 
+// ****************************************************************
+// RegisterDevice - Register the device of the staff to allow the
+// Clock-In/Clock-Out from it, device_fingerprint is expected in
+// indata along with optional device_name and registered_by
+//
+// ****************************************************************
+func (p *staffBaseService) RegisterDevice(staff_id string, indata utils.Map) (utils.Map, error) {
+
+	log.Println("StaffService::RegisterDevice - Begin", staff_id)
+
+	staffData, err := p.daoStaff.Get(staff_id)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := utils.GetMemberDataStr(indata, hr_common.FLD_DEVICE_FINGERPRINT)
+	if err != nil || len(fingerprint) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "No device_fingerprint", ErrorDetail: "Fingerprint of the device should be sent in device_fingerprint"}
+		return nil, err
+	}
+
+	devices := getRegisteredDevices(staffData)
+	if findRegisteredDevice(devices, fingerprint) >= 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Existing Device", ErrorDetail: "Given device already registered for the staff"}
+		return nil, err
+	}
+
+	device := utils.Map{
+		hr_common.FLD_DEVICE_FINGERPRINT: fingerprint,
+		hr_common.FLD_REGISTERED_AT:      time.Now(),
+	}
+	if deviceName, err := utils.GetMemberDataStr(indata, hr_common.FLD_DEVICE_NAME); err == nil {
+		device[hr_common.FLD_DEVICE_NAME] = deviceName
+	}
+	if registeredBy, err := utils.GetMemberDataStr(indata, hr_common.FLD_REGISTERED_BY); err == nil {
+		device[hr_common.FLD_REGISTERED_BY] = registeredBy
+	}
+	devices = append(devices, device)
+
+	_, err = p.daoStaff.Update(staff_id, utils.Map{hr_common.FLD_REGISTERED_DEVICES: devices})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("StaffService::RegisterDevice - End")
+	return device, nil
+}
+
+// ****************************************************************
+// RevokeDevice - Remove the device from the registered devices of
+// the staff
+//
+// ****************************************************************
+func (p *staffBaseService) RevokeDevice(staff_id string, device_fingerprint string) (utils.Map, error) {
+
+	log.Println("StaffService::RevokeDevice - Begin", staff_id)
+
+	staffData, err := p.daoStaff.Get(staff_id)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := getRegisteredDevices(staffData)
+	index := findRegisteredDevice(devices, device_fingerprint)
+	if index < 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Invalid Device", ErrorDetail: "Given device is not registered for the staff"}
+		return nil, err
+	}
+	device := devices[index]
+	devices = append(devices[:index], devices[index+1:]...)
+
+	_, err = p.daoStaff.Update(staff_id, utils.Map{hr_common.FLD_REGISTERED_DEVICES: devices})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("StaffService::RevokeDevice - End")
+	return device, nil
+}
+
+// ****************************************************************
+// ListDevices - List the registered devices of the staff
+//
+// ****************************************************************
+func (p *staffBaseService) ListDevices(staff_id string) (utils.Map, error) {
+
+	log.Println("StaffService::ListDevices - Begin", staff_id)
+
+	staffData, err := p.daoStaff.Get(staff_id)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := getRegisteredDevices(staffData)
+	response := utils.Map{
+		hr_common.FLD_STAFF_ID:           staff_id,
+		hr_common.FLD_REGISTERED_DEVICES: devices,
+	}
+
+	log.Println("StaffService::ListDevices - End", len(devices))
+	return response, nil
+}
+
 func (p *staffBaseService) errorReturn(err error) (StaffService, error) {
 	// Close the Database Connection
 	p.EndService()
@@ -239,3 +352,28 @@ func (p *staffBaseService) mergeUserInfo(staffInfo utils.Map) {
 		staffInfo[hr_common.FLD_STAFF_INFO] = []utils.Map{staffData}
 	}
 }
+
+// getRegisteredDevices - Get the devices registered for the staff
+func getRegisteredDevices(staffData utils.Map) []utils.Map {
+
+	devices := []utils.Map{}
+	deviceList, _ := hr_common.ToArray(staffData[hr_common.FLD_REGISTERED_DEVICES])
+	for _, deviceVal := range deviceList {
+		if device, ok := hr_common.ToMap(deviceVal); ok {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// findRegisteredDevice - Index of the device having the fingerprint, -1 when not found
+func findRegisteredDevice(devices []utils.Map, fingerprint string) int {
+
+	for index, device := range devices {
+		deviceFingerprint, _ := utils.GetMemberDataStr(device, hr_common.FLD_DEVICE_FINGERPRINT)
+		if deviceFingerprint == fingerprint {
+			return index
+		}
+	}
+	return -1
+}
@@ -1,8 +1,10 @@
 package hr_services
 
 import (
+	"encoding/json"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/zapscloud/golib-dbutils/db_common"
 	"github.com/zapscloud/golib-dbutils/db_utils"
@@ -21,6 +23,8 @@ type WorkLocationService interface {
 	Create(indata utils.Map) (utils.Map, error)
 	Update(workLocId string, indata utils.Map) (utils.Map, error)
 	Delete(workLocId string, delete_permanent bool) error
+	ResetKioskSecret(workLocId string) (utils.Map, error)
+	GetKioskToken(workLocId string) (utils.Map, error)
 
 	BeginTransaction()
 	CommitTransaction()
@@ -39,6 +43,13 @@ type workLocationBaseService struct {
 	businessID          string
 }
 
+const (
+	// Seconds each kiosk token is valid for, when not configured in the work location
+	DEFAULT_KIOSK_TOKEN_INTERVAL = 30
+	// Tokens of the previous/next interval are also accepted to allow the scan delay
+	KIOSK_TOKEN_SKEW = 1
+)
+
 func init() {
 	log.SetFlags(log.Lshortfile | log.LstdFlags | log.Lmicroseconds)
 }
@@ -106,6 +117,12 @@ func (p *workLocationBaseService) List(filter string, sort string, skip int64, l
 		return nil, err
 	}
 
+	if workLocs, ok := response[db_common.LIST_RESULT].([]utils.Map); ok {
+		for _, workLocData := range workLocs {
+			hideKioskSecret(workLocData)
+		}
+	}
+
 	log.Println("AccountService::FindAll - End ")
 	return response, nil
 }
@@ -115,6 +132,7 @@ func (p *workLocationBaseService) Get(workLocId string) (utils.Map, error) {
 	log.Printf("AccountService::FindByCode::  Begin %v", workLocId)
 
 	data, err := p.daoWorkLocation.Get(workLocId)
+	hideKioskSecret(data)
 	log.Println("AccountService::FindByCode:: End ", err)
 	return data, err
 }
@@ -123,6 +141,7 @@ func (p *workLocationBaseService) Find(filter string) (utils.Map, error) {
 	log.Println("AccountService::FindByCode::  Begin ", filter)
 
 	data, err := p.daoWorkLocation.Find(filter)
+	hideKioskSecret(data)
 	log.Println("AccountService::FindByCode:: End ", data, err)
 	return data, err
 }
@@ -150,6 +169,19 @@ func (p *workLocationBaseService) Create(indata utils.Map) (utils.Map, error) {
 		return indata, err
 	}
 
+	// Validate Geofence values
+	err = p.validateGeofence(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	// Kiosk secret is generated with ResetKioskSecret only
+	delete(indata, hr_common.FLD_KIOSK_SECRET)
+	err = p.validateKiosk(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	insertResult, err := p.daoWorkLocation.Create(indata)
 	if err != nil {
 		return indata, err
@@ -172,6 +204,19 @@ func (p *workLocationBaseService) Update(workLocId string, indata utils.Map) (ut
 	delete(indata, hr_common.FLD_WORKLOCATION_ID)
 	delete(indata, hr_common.FLD_BUSINESS_ID)
 
+	// Validate Geofence values
+	err = p.validateGeofence(indata)
+	if err != nil {
+		return indata, err
+	}
+
+	// Kiosk secret is generated with ResetKioskSecret only
+	delete(indata, hr_common.FLD_KIOSK_SECRET)
+	err = p.validateKiosk(indata)
+	if err != nil {
+		return indata, err
+	}
+
 	data, err = p.daoWorkLocation.Update(workLocId, indata)
 	log.Println("AccountService::Update - End ")
 	return data, err
@@ -207,8 +252,158 @@ func (p *workLocationBaseService) Delete(workLocId string, delete_permanent bool
 	return nil
 }
 
+// ****************************************************************
+// ResetKioskSecret - Generate a new secret for the kiosk tokens of
+// the work location, this enables the kiosk mode and invalidates the
+// tokens generated so far
+//
+// ****************************************************************
+func (p *workLocationBaseService) ResetKioskSecret(workLocId string) (utils.Map, error) {
+
+	log.Println("WorkLocationService::ResetKioskSecret - Begin", workLocId)
+
+	_, err := p.daoWorkLocation.Get(workLocId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := hr_common.GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.daoWorkLocation.Update(workLocId, utils.Map{hr_common.FLD_KIOSK_SECRET: secret})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("WorkLocationService::ResetKioskSecret - End")
+	return p.GetKioskToken(workLocId)
+}
+
+// ****************************************************************
+// GetKioskToken - Get the current kiosk token of the work location
+// along with the QR code payload to be shown at the kiosk, the kiosk
+// is expected to refresh it on kiosk_token_expires_at
+//
+// ****************************************************************
+func (p *workLocationBaseService) GetKioskToken(workLocId string) (utils.Map, error) {
+
+	log.Println("WorkLocationService::GetKioskToken - Begin", workLocId)
+
+	workLocData, err := p.daoWorkLocation.Get(workLocId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, _ := utils.GetMemberDataStr(workLocData, hr_common.FLD_KIOSK_SECRET)
+	if len(secret) == 0 {
+		err := &utils.AppError{ErrorCode: "S30102", ErrorMsg: "Kiosk not enabled", ErrorDetail: "Kiosk secret is not generated for the work location"}
+		return nil, err
+	}
+
+	interval := getKioskTokenInterval(workLocData)
+	token, stepStart, err := hr_common.GenerateTotp(secret, time.Now(), interval)
+	if err != nil {
+		return nil, err
+	}
+
+	qrPayload, _ := json.Marshal(utils.Map{
+		hr_common.FLD_WORKLOCATION: workLocId,
+		hr_common.FLD_KIOSK_TOKEN:  token,
+	})
+
+	response := utils.Map{
+		hr_common.FLD_WORKLOCATION_ID:        workLocId,
+		hr_common.FLD_KIOSK_TOKEN:            token,
+		hr_common.FLD_KIOSK_TOKEN_EXPIRES_AT: stepStart.Add(interval),
+		hr_common.FLD_KIOSK_QR_PAYLOAD:       string(qrPayload),
+	}
+
+	log.Println("WorkLocationService::GetKioskToken - End")
+	return response, nil
+}
+
 func (p *workLocationBaseService) errorReturn(err error) (WorkLocationService, error) {
 	// Close the Database Connection
 	p.EndService()
 	return nil, err
 }
+
+func (p *workLocationBaseService) validateGeofence(indata utils.Map) error {
+
+	// Validate Center point if given
+	_, latOk := indata[hr_common.FLD_LATITUDE]
+	_, lngOk := indata[hr_common.FLD_LONGITUDE]
+	if latOk || lngOk {
+		_, err := hr_common.GetGeoPoint(indata)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate Radius if given
+	if _, dataOk := indata[hr_common.FLD_GEOFENCE_RADIUS]; dataOk {
+		radius, err := hr_common.GetMemberDataFloat(indata, hr_common.FLD_GEOFENCE_RADIUS)
+		if err != nil || radius <= 0 {
+			err = &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid geofence_radius",
+				ErrorDetail: "geofence_radius should be a positive number in meters"}
+			return err
+		}
+	}
+
+	// Validate Polygon if given
+	if dataVal, dataOk := indata[hr_common.FLD_GEOFENCE_POLYGON]; dataOk {
+		_, err := hr_common.GetGeoPolygon(dataVal)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate Action if given
+	if dataVal, dataOk := indata[hr_common.FLD_GEOFENCE_ACTION]; dataOk {
+		if dataVal != hr_common.GEOFENCE_ACTION_REJECT && dataVal != hr_common.GEOFENCE_ACTION_FLAG {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid geofence_action",
+				ErrorDetail: "geofence_action should be either reject or flag"}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *workLocationBaseService) validateKiosk(indata utils.Map) error {
+
+	// Validate Token interval if given
+	if _, dataOk := indata[hr_common.FLD_KIOSK_TOKEN_INTERVAL]; dataOk {
+		interval, err := utils.GetMemberDataInt(indata, hr_common.FLD_KIOSK_TOKEN_INTERVAL, true)
+		if err != nil || interval <= 0 {
+			err := &utils.AppError{
+				ErrorCode:   "S30102",
+				ErrorMsg:    "Invalid kiosk_token_interval",
+				ErrorDetail: "kiosk_token_interval should be a positive number in seconds"}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hideKioskSecret - Remove the kiosk secret from the work location returned to the clients
+func hideKioskSecret(workLocData utils.Map) {
+	delete(workLocData, hr_common.FLD_KIOSK_SECRET)
+}
+
+// getKioskTokenInterval - Duration each kiosk token of the work location is valid for
+func getKioskTokenInterval(workLocData utils.Map) time.Duration {
+
+	interval, err := utils.GetMemberDataInt(workLocData, hr_common.FLD_KIOSK_TOKEN_INTERVAL, true)
+	if err != nil || interval <= 0 {
+		interval = DEFAULT_KIOSK_TOKEN_INTERVAL
+	}
+	return time.Duration(interval) * time.Second
+}